@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"sync"
+
+	"lrucache/simple"
+	"lrucache/ttl"
+)
+
+// Cache is the method set shared by the cache implementations, so callers
+// can switch between them, or disable caching, without changing call sites.
+// Reads go through Lookup because simple.LRU's Get returns only the value.
+type Cache interface {
+	Add(key string, value any)
+	Lookup(key string) (any, bool)
+	Remove(key string) bool
+	Len() int
+	Purge()
+}
+
+var (
+	_ Cache = (*ttl.LRU)(nil)
+	_ Cache = (*ttl.ShardedLRU)(nil)
+	_ Cache = (*simple.LRU)(nil)
+	_ Cache = NopCache{}
+	_ Cache = (*UnboundedCache)(nil)
+)
+
+// NopCache stores nothing: every Get is a miss.
+type NopCache struct{}
+
+func (NopCache) Add(key string, value any) {}
+
+func (NopCache) Get(key string) (any, bool) {
+	return nil, false
+}
+
+func (NopCache) Lookup(key string) (any, bool) {
+	return nil, false
+}
+
+func (NopCache) Remove(key string) bool {
+	return false
+}
+
+func (NopCache) Len() int {
+	return 0
+}
+
+func (NopCache) Purge() {}
+
+// UnboundedCache keeps every entry until it is removed or purged.
+type UnboundedCache struct {
+	mu    sync.Mutex
+	items map[string]any
+}
+
+func NewUnboundedCache() *UnboundedCache {
+	return &UnboundedCache{
+		items: make(map[string]any),
+	}
+}
+
+func (c *UnboundedCache) Add(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = value
+}
+
+func (c *UnboundedCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.items[key]
+	return value, ok
+}
+
+func (c *UnboundedCache) Lookup(key string) (any, bool) {
+	return c.Get(key)
+}
+
+func (c *UnboundedCache) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.items[key]
+	delete(c.items, key)
+	return ok
+}
+
+func (c *UnboundedCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *UnboundedCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.items)
+}
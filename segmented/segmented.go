@@ -0,0 +1,133 @@
+package segmented
+
+import (
+	"container/list"
+	"sync"
+)
+
+type Item struct {
+	Key   string
+	Value any
+
+	protected bool
+}
+
+// LRU is a segmented (2Q-style) cache. New keys are admitted into a
+// probationary segment and only move to the protected segment when they are
+// accessed again, so a flood of one-time keys can only churn the
+// probationary segment.
+type LRU struct {
+	mu sync.Mutex
+
+	probationCap int
+	protectedCap int
+
+	items     map[string]*list.Element
+	probation *list.List
+	protected *list.List
+}
+
+func NewLRU(probationCap, protectedCap int) *LRU {
+	if probationCap < 1 {
+		probationCap = 1
+	}
+	if protectedCap < 0 {
+		protectedCap = 0
+	}
+
+	return &LRU{
+		probationCap: probationCap,
+		protectedCap: protectedCap,
+		items:        make(map[string]*list.Element),
+		probation:    list.New(),
+		protected:    list.New(),
+	}
+}
+
+func (c *LRU) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ent, ok := c.items[key]; ok {
+		ent.Value.(*Item).Value = value
+		c.hit(ent)
+		return
+	}
+
+	if c.probation.Len() == c.probationCap {
+		c.removeElement(c.probation.Back())
+	}
+
+	item := &Item{
+		Key:   key,
+		Value: value,
+	}
+	c.items[key] = c.probation.PushFront(item)
+}
+
+func (c *LRU) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ent, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.hit(ent)
+	return ent.Value.(*Item).Value, true
+}
+
+func (c *LRU) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ent, ok := c.items[key]; ok {
+		c.removeElement(ent)
+		return true
+	}
+	return false
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *LRU) hit(e *list.Element) {
+	item := e.Value.(*Item)
+	if item.protected {
+		c.protected.MoveToFront(e)
+		return
+	}
+	if c.protectedCap == 0 {
+		c.probation.MoveToFront(e)
+		return
+	}
+
+	c.probation.Remove(e)
+	if c.protected.Len() == c.protectedCap {
+		c.demote(c.protected.Back())
+	}
+	item.protected = true
+	c.items[item.Key] = c.protected.PushFront(item)
+}
+
+func (c *LRU) demote(e *list.Element) {
+	item := c.protected.Remove(e).(*Item)
+	item.protected = false
+	if c.probation.Len() == c.probationCap {
+		c.removeElement(c.probation.Back())
+	}
+	c.items[item.Key] = c.probation.PushFront(item)
+}
+
+func (c *LRU) removeElement(e *list.Element) {
+	item := e.Value.(*Item)
+	if item.protected {
+		c.protected.Remove(e)
+	} else {
+		c.probation.Remove(e)
+	}
+	delete(c.items, item.Key)
+}
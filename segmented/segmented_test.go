@@ -0,0 +1,31 @@
+package segmented
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestProtectedSurvivesOneTimeKeys(t *testing.T) {
+	c := NewLRU(4, 4)
+
+	hot := []string{"h0", "h1", "h2", "h3"}
+	for _, k := range hot {
+		c.Set(k, k)
+		c.Get(k)
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set("scan-"+strconv.Itoa(i), i)
+	}
+
+	for _, k := range hot {
+		if v, ok := c.Get(k); !ok || v != k {
+			t.Fatalf("Get(%q) = %v, %v after a scan, want %q, true", k, v, ok, k)
+		}
+	}
+	if n := c.Len(); n != 8 {
+		t.Fatalf("Len = %d, want 8", n)
+	}
+	if _, ok := c.Get("scan-0"); ok {
+		t.Fatal("early one-time key still cached")
+	}
+}
@@ -1,60 +1,92 @@
 package simple
 
-import "container/list"
+import (
+	"sync"
 
+	"lrucache/ttl"
+)
+
+// Item is a key and its value. The cache no longer stores Items itself; the
+// type is kept so code built against earlier versions still compiles.
 type Item struct {
 	Key   string
 	Value interface{}
 }
 
+// LRU is a ttl.LRU without expiry: entries only leave the cache through
+// capacity eviction. The zero value is an empty cache with no capacity
+// limit; use NewLru to bound it.
 type LRU struct {
-	cap   int
-	items map[string]*list.Element
-	queue *list.List
+	once sync.Once
+	lru  *ttl.LRU
+}
+
+type Option = ttl.Option
+
+// WithEqualityFunc makes Set skip the overwrite and the promotion when the
+// new value is equal to the cached one.
+func WithEqualityFunc(eq func(a, b interface{}) bool) Option {
+	return ttl.WithEqualityFunc(eq)
 }
 
-func NewLru(cap int) *LRU {
+func NewLru(cap int, opts ...Option) *LRU {
 	return &LRU{
-		cap:   cap,
-		items: make(map[string]*list.Element),
-		queue: list.New(),
+		lru: ttl.NewLRU(cap, 0, opts...),
 	}
 }
 
+// cache returns the underlying cache, creating it for a zero LRU.
+func (c *LRU) cache() *ttl.LRU {
+	c.once.Do(func() {
+		if c.lru == nil {
+			c.lru = ttl.NewLRU(0, 0)
+		}
+	})
+	return c.lru
+}
+
 func (c *LRU) Set(key string, value interface{}) {
-	if element, exist := c.items[key]; exist {
-		c.queue.MoveToFront(element)
-		element.Value.(*Item).Value = value
-		return
-	}
+	c.cache().Add(key, value)
+}
 
-	if c.queue.Len() == c.cap {
-		c.purge()
-	}
+func (c *LRU) Get(key string) interface{} {
+	value, _ := c.cache().Get(key)
+	return value
+}
 
-	item := &Item{
-		Key:   key,
-		Value: value,
-	}
+func (c *LRU) GetOrDefault(key string, def interface{}) interface{} {
+	return c.cache().GetOrDefault(key, def)
+}
 
-	element := c.queue.PushFront(item)
-	c.items[item.Key] = element
+// Lookup is like Get but also reports whether the key was present, which
+// tells a miss apart from a stored nil value.
+func (c *LRU) Lookup(key string) (interface{}, bool) {
+	return c.cache().Get(key)
+}
 
-	return
+// Add is the same as Set.
+func (c *LRU) Add(key string, value interface{}) {
+	c.cache().Add(key, value)
 }
 
-func (c *LRU) purge() {
-	if element := c.queue.Back(); element != nil {
-		item := c.queue.Remove(element).(*Item)
-		delete(c.items, item.Key)
-	}
+func (c *LRU) Len() int {
+	return c.cache().Len()
 }
 
-func (c *LRU) Get(key string) interface{} {
-	element, exist := c.items[key]
-	if !exist {
-		return nil
-	}
-	c.queue.MoveToFront(element)
-	return element.Value.(*Item).Value
+func (c *LRU) Purge() {
+	c.cache().Purge()
+}
+
+func (c *LRU) Remove(key string) bool {
+	return c.cache().Remove(key)
+}
+
+// ForEachKey calls fn with each key, from the most to the least recently
+// used, until fn returns false. fn must not call back into the cache.
+func (c *LRU) ForEachKey(fn func(key string) bool) {
+	c.cache().ForEachKey(fn)
+}
+
+func (c *LRU) RemoveOldest() (string, interface{}, bool) {
+	return c.cache().RemoveOldest()
 }
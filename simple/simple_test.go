@@ -0,0 +1,87 @@
+package simple
+
+import "testing"
+
+func TestSetGet(t *testing.T) {
+	c := NewLru(2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if v := c.Get("a"); v != 1 {
+		t.Fatalf("Get(a) = %v, want 1", v)
+	}
+	if v := c.Get("b"); v != nil {
+		t.Fatalf("Get(b) = %v, want the least recently used key evicted", v)
+	}
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len = %d, want 2", n)
+	}
+}
+
+func TestLookupTellsNilFromMiss(t *testing.T) {
+	c := NewLru(2)
+	c.Set("nil", nil)
+
+	if v, ok := c.Lookup("nil"); !ok || v != nil {
+		t.Fatalf("Lookup(nil) = %v, %v, want nil, true", v, ok)
+	}
+	if _, ok := c.Lookup("missing"); ok {
+		t.Fatal("Lookup of a missing key reported a hit")
+	}
+}
+
+func TestGetOrDefault(t *testing.T) {
+	c := NewLru(2)
+	c.Set("a", 1)
+
+	if v := c.GetOrDefault("a", 0); v != 1 {
+		t.Fatalf("GetOrDefault(a) = %v, want 1", v)
+	}
+	if v := c.GetOrDefault("missing", 42); v != 42 {
+		t.Fatalf("GetOrDefault(missing) = %v, want 42", v)
+	}
+}
+
+func TestRemoveOldest(t *testing.T) {
+	c := NewLru(3)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+
+	for _, want := range []string{"b", "c", "a"} {
+		key, _, ok := c.RemoveOldest()
+		if !ok || key != want {
+			t.Fatalf("RemoveOldest = %q, %v, want %q, true", key, ok, want)
+		}
+	}
+	if _, _, ok := c.RemoveOldest(); ok {
+		t.Fatal("RemoveOldest on an empty cache reported an entry")
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var c LRU
+	for i := 0; i < 100; i++ {
+		c.Set(string(rune('a'+i%26))+string(rune('a'+i/26)), i)
+	}
+	if n := c.Len(); n != 100 {
+		t.Fatalf("Len = %d, want 100: the zero LRU must be unbounded", n)
+	}
+	if !c.Remove("aa") || c.Len() != 99 {
+		t.Fatalf("Remove on the zero LRU failed, Len = %d", c.Len())
+	}
+	c.Purge()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len after Purge = %d, want 0", n)
+	}
+}
+
+func TestItemLiteral(t *testing.T) {
+	item := Item{"k", 1}
+	if item.Key != "k" || item.Value != 1 {
+		t.Fatalf("Item = %+v", item)
+	}
+}
@@ -0,0 +1,128 @@
+package tiered
+
+import (
+	"time"
+
+	"lrucache/cache"
+	"lrucache/simple"
+	"lrucache/ttl"
+)
+
+// TieredCache puts a small simple.LRU in front of a larger ttl.LRU. Writes go
+// to both tiers, reads are served by L1 first and L2 hits are copied into L1.
+// Entries evicted from L1 fall down into L2 if L2 no longer holds them.
+// L1 entries carry the deadline of their L2 entry, so an L1 hit never
+// outlives the L2 TTL.
+type TieredCache struct {
+	l1    *simple.LRU
+	l2    *ttl.LRU
+	l2TTL time.Duration
+}
+
+var _ cache.Cache = (*TieredCache)(nil)
+
+// entry is an L1 value with the deadline it has in L2. A zero expiresAt
+// never expires.
+type entry struct {
+	value     any
+	expiresAt time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func NewTieredCache(l1Cap, l2Cap int, l2TTL time.Duration, opts ...ttl.Option) *TieredCache {
+	c := &TieredCache{
+		l2:    ttl.NewLRU(l2Cap, l2TTL, opts...),
+		l2TTL: l2TTL,
+	}
+	c.l1 = simple.NewLru(l1Cap, ttl.WithOnEvict(c.demote))
+	return c
+}
+
+// demote moves an entry evicted from L1 into L2 with the TTL it has left.
+// Checking L2 with IsExpired leaves L2's recency and stats alone.
+func (c *TieredCache) demote(key string, value any) {
+	e := value.(entry)
+	now := time.Now()
+	if e.expired(now) {
+		return
+	}
+	if expired, ok := c.l2.IsExpired(key); ok && !expired {
+		return
+	}
+	var left time.Duration
+	if !e.expiresAt.IsZero() {
+		left = e.expiresAt.Sub(now)
+	}
+	c.l2.AddWithTTL(key, e.value, left)
+}
+
+func (c *TieredCache) Get(key string) (any, bool) {
+	if value, ok := c.l1.Lookup(key); ok {
+		if e := value.(entry); !e.expired(time.Now()) {
+			return e.value, true
+		}
+		c.l1.Remove(key)
+	}
+	view, ok := c.l2.GetEntry(key)
+	if !ok {
+		return nil, false
+	}
+	c.l1.Set(key, entry{value: view.Value, expiresAt: view.ExpiresAt})
+	return view.Value, true
+}
+
+func (c *TieredCache) Set(key string, value any) {
+	c.l2.Add(key, value)
+	e := entry{value: value}
+	if c.l2TTL > 0 {
+		e.expiresAt = time.Now().Add(c.l2TTL)
+	}
+	c.l1.Set(key, e)
+}
+
+// Lookup is the same as Get.
+func (c *TieredCache) Lookup(key string) (any, bool) {
+	return c.Get(key)
+}
+
+// Add is the same as Set.
+func (c *TieredCache) Add(key string, value any) {
+	c.Set(key, value)
+}
+
+// Len returns the number of distinct keys held by either tier. Like
+// ttl.LRU's Len it counts expired entries that haven't been removed yet.
+func (c *TieredCache) Len() int {
+	var l1Keys []string
+	c.l1.ForEachKey(func(key string) bool {
+		l1Keys = append(l1Keys, key)
+		return true
+	})
+	n := c.l2.Len()
+	for _, key := range l1Keys {
+		if _, ok := c.l2.IsExpired(key); !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Purge empties both tiers.
+func (c *TieredCache) Purge() {
+	c.l1.Purge()
+	c.l2.Purge()
+}
+
+func (c *TieredCache) Remove(key string) bool {
+	inL1 := c.l1.Remove(key)
+	inL2 := c.l2.Remove(key)
+	return inL1 || inL2
+}
+
+// Close stops the L2 sweeper.
+func (c *TieredCache) Close() {
+	c.l2.Close()
+}
@@ -0,0 +1,57 @@
+package tiered
+
+import (
+	"testing"
+	"time"
+)
+
+func TestL1HitExpiresWithL2(t *testing.T) {
+	c := NewTieredCache(2, 10, 20*time.Millisecond)
+	defer c.Close()
+
+	c.Set("k", 1)
+	if v, ok := c.Get("k"); !ok || v != 1 {
+		t.Fatalf("Get = %v, %v, want 1, true", v, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, ok := c.Get("k"); ok {
+		t.Fatalf("Get after the TTL = %v, want a miss", v)
+	}
+}
+
+func TestDemoteKeepsDeadline(t *testing.T) {
+	c := NewTieredCache(1, 10, 30*time.Millisecond)
+	defer c.Close()
+
+	c.Set("a", 1)
+	c.l2.Remove("a")
+	c.Set("b", 2) // evicts a from L1 into L2
+	if v, ok := c.l2.Get("a"); !ok || v != 1 {
+		t.Fatalf("demoted entry = %v, %v, want 1, true", v, ok)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("demoted entry outlived its original deadline")
+	}
+}
+
+func TestLenAndPurge(t *testing.T) {
+	c := NewTieredCache(2, 10, time.Minute)
+	defer c.Close()
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.l2.Remove("a") // only L1 holds a now
+	c.Add("c", 3)    // demotes a back into L2
+	c.l2.Remove("b")
+	if n := c.Len(); n != 3 {
+		t.Fatalf("Len = %d, want 3", n)
+	}
+	c.Purge()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len after Purge = %d, want 0", n)
+	}
+	if _, ok := c.Lookup("a"); ok {
+		t.Fatal("Lookup hit after Purge")
+	}
+}
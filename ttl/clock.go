@@ -0,0 +1,16 @@
+package ttl
+
+import "time"
+
+// Clock is the time source a cache uses for entry timestamps and expiry,
+// see WithClock.
+type Clock interface {
+	Now() time.Time
+}
+
+func (c *LRU) now() time.Time {
+	if c.clock != nil {
+		return c.clock.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,114 @@
+package ttl
+
+import (
+	"container/list"
+	"time"
+)
+
+// Touch refreshes the TTL of a live entry and updates its recency as Get
+// would, without reading or writing the value. It reports whether the key
+// was live.
+func (c *LRU) Touch(key string) bool {
+	c.lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	ent, ok := c.items[key]
+	if !ok || ent.Value.(*Item).expired(now) {
+		return false
+	}
+	c.touch(ent, now)
+	return true
+}
+
+func (c *LRU) touch(e *list.Element, now time.Time) {
+	c.renew(e, now, e.Value.(*Item).ttl)
+	if c.policy == PolicyLRU {
+		c.promote(e)
+	}
+}
+
+// renew moves the deadline of a live entry to now+ttl. Under
+// WithAbsoluteLifetime the entry keeps the TTL it was added with and the new
+// deadline is capped at the end of that lifetime, CreatedAt plus the TTL.
+func (c *LRU) renew(e *list.Element, now time.Time, ttl time.Duration) {
+	item := e.Value.(*Item)
+	deadline := expiresAt(now, ttl)
+	if !c.absoluteLifetime {
+		item.ttl = ttl
+	} else if item.ttl > 0 {
+		if end := item.CreatedAt.Add(item.ttl); deadline.IsZero() || deadline.After(end) {
+			deadline = end
+		}
+	}
+	c.removeFromBucket(e)
+	item.ExpiresAt = deadline
+	c.addToBucket(e)
+}
+
+// RenewMulti gives each live entry among keys a fresh ttl, like
+// GetAndMaybeRenew does for one key, under a single lock hold. Recency is
+// not changed. It returns how many entries were renewed.
+func (c *LRU) RenewMulti(keys []string, ttl time.Duration) int {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	n := 0
+	for _, key := range keys {
+		ent, ok := c.items[key]
+		if !ok || ent.Value.(*Item).expired(now) {
+			continue
+		}
+		c.renew(ent, now, ttl)
+		n++
+	}
+	return n
+}
+
+// ResetAccessCount sets the Accesses count of a live entry back to zero,
+// leaving its value, recency and TTL alone, and reports whether the key was
+// live. The WithTinyLFU frequency sketch is not per entry and is unaffected.
+func (c *LRU) ResetAccessCount(key string) bool {
+	c.lock()
+	defer c.mu.Unlock()
+	ent, ok := c.items[key]
+	if !ok || ent.Value.(*Item).expired(c.now()) {
+		return false
+	}
+	ent.Value.(*Item).Accesses = 0
+	return true
+}
+
+// Increment adds delta to the int64 stored under key and returns the result.
+// A missing or expired key is added with the value delta and the default
+// TTL. It returns false, changing nothing, if the live value isn't an int64.
+//
+// An increment by 0 is a touch: a live entry gets its TTL refreshed and its
+// recency updated like Touch, without counting as a write, and a missing key
+// is not added. This lets rate limiters probe a counter without consuming it.
+func (c *LRU) Increment(key string, delta int64) (int64, bool) {
+	c.lock()
+	defer c.unlock()
+	if c.closed {
+		return 0, false
+	}
+	now := c.now()
+	ent, ok := c.items[key]
+	if !ok || ent.Value.(*Item).expired(now) {
+		if delta != 0 && c.checkedAdd(key, delta, c.ttl, now, 0) != nil {
+			return 0, false
+		}
+		return delta, true
+	}
+	current, ok := ent.Value.(*Item).value().(int64)
+	if !ok {
+		return 0, false
+	}
+	if delta == 0 {
+		c.touch(ent, now)
+		return current, true
+	}
+	if c.checkedAdd(key, current+delta, ent.Value.(*Item).ttl, now, 0) != nil {
+		return 0, false
+	}
+	return current + delta, true
+}
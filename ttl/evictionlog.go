@@ -0,0 +1,63 @@
+package ttl
+
+import "time"
+
+// EvictionRecord describes an entry the cache dropped on its own, see
+// WithEvictionLog. Expired tells a TTL expiry apart from an eviction made
+// to stay within capacity.
+type EvictionRecord struct {
+	Key     string
+	At      time.Time
+	Expired bool
+}
+
+// evictionLog is a ring buffer of the most recent evictions and expiries.
+type evictionLog struct {
+	records []EvictionRecord
+	next    int
+	full    bool
+}
+
+func (l *evictionLog) record(key string, r reason, now time.Time) {
+	if len(l.records) == 0 {
+		return
+	}
+	l.records[l.next] = EvictionRecord{Key: key, At: now, Expired: r == reasonExpired}
+	l.next++
+	if l.next == len(l.records) {
+		l.next = 0
+		l.full = true
+	}
+}
+
+// RecentEvictions returns the records kept by WithEvictionLog, oldest first.
+// It returns nil when the log is disabled.
+func (c *LRU) RecentEvictions() []EvictionRecord {
+	c.lock()
+	defer c.mu.Unlock()
+	l := &c.evictionLog
+	if !l.full {
+		return append([]EvictionRecord(nil), l.records[:l.next]...)
+	}
+	return append(append([]EvictionRecord(nil), l.records[l.next:]...), l.records[:l.next]...)
+}
+
+// EvictionRate returns the capacity evictions per second over the last
+// window, counted from the WithEvictionLog records; expiries are not
+// included. It is 0 when the log is disabled. The count is capped by the
+// log's size, so a log too small to cover the window reports a lower rate.
+func (c *LRU) EvictionRate(window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+	c.lock()
+	defer c.mu.Unlock()
+	since := c.now().Add(-window)
+	n := 0
+	for _, r := range c.evictionLog.records {
+		if !r.Expired && r.At.After(since) {
+			n++
+		}
+	}
+	return float64(n) / window.Seconds()
+}
@@ -0,0 +1,72 @@
+package ttl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxPreviewLen = 64
+
+// Dump returns one line per entry, sorted by key, with the entry's recency
+// position (0 is the most recently used), a truncated value and the time
+// left until it expires. The entries are copied under the lock and
+// formatted after it is released.
+func (c *LRU) Dump() string {
+	type line struct {
+		key       string
+		value     any
+		pos       int
+		expiresAt time.Time
+	}
+
+	c.lock()
+	lines := make([]line, 0, c.queue.Len())
+	pos := 0
+	for ent := c.queue.Front(); ent != nil; ent = ent.Next() {
+		item := ent.Value.(*Item)
+		lines = append(lines, line{key: item.Key, value: item.value(), pos: pos, expiresAt: item.ExpiresAt})
+		pos++
+	}
+	c.mu.Unlock()
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i].key < lines[j].key })
+
+	now := c.now()
+	var b strings.Builder
+	for _, l := range lines {
+		expiry := "never"
+		if !l.expiresAt.IsZero() {
+			if left := l.expiresAt.Sub(now); left > 0 {
+				expiry = left.String()
+			} else {
+				expiry = "expired"
+			}
+		}
+		fmt.Fprintf(&b, "%q pos=%d ttl=%s value=%s\n", l.key, l.pos, expiry, preview(l.value))
+	}
+	return b.String()
+}
+
+func preview(v any) string {
+	s := fmt.Sprintf("%v", v)
+	if len(s) > maxPreviewLen {
+		return s[:maxPreviewLen] + "..."
+	}
+	return s
+}
+
+func (i *Item) String() string {
+	expiry := "never"
+	if !i.ExpiresAt.IsZero() {
+		expiry = i.ExpiresAt.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%q: %s (expires %s)", i.Key, preview(i.value()), expiry)
+}
+
+func (c *LRU) String() string {
+	c.lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("ttl.LRU{len: %d, cap: %d, ttl: %s}", len(c.items), c.cap, c.ttl)
+}
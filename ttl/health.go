@@ -0,0 +1,90 @@
+package ttl
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthCheck verifies the cache's internal invariants: the map and the
+// recency list hold the same entries, every expiring entry sits in exactly
+// the bucket recorded in its ExpireBucket, buckets only hold resident
+// entries and know their latest deadline. It returns an error describing the
+// first mismatch found.
+func (c *LRU) HealthCheck() error {
+	c.lock()
+	defer c.mu.Unlock()
+
+	if len(c.items) != c.queue.Len() {
+		return fmt.Errorf("ttl: %d items in map but %d in list", len(c.items), c.queue.Len())
+	}
+
+	var size int64
+	for ent := c.queue.Front(); ent != nil; ent = ent.Next() {
+		item := ent.Value.(*Item)
+		if c.items[item.Key] != ent {
+			return fmt.Errorf("ttl: list entry %q is not the map entry", item.Key)
+		}
+		if !item.ExpiresAt.IsZero() && c.precise {
+			if item.expiryIndex >= len(c.expiryQueue) || c.expiryQueue[item.expiryIndex] != ent {
+				return fmt.Errorf("ttl: entry %q missing from the expiry queue", item.Key)
+			}
+		} else if !item.ExpiresAt.IsZero() {
+			if int(item.ExpireBucket) >= len(c.buckets) {
+				return fmt.Errorf("ttl: entry %q has invalid bucket %d", item.Key, item.ExpireBucket)
+			}
+			if c.buckets[item.ExpireBucket].entries[item.Key] != ent {
+				return fmt.Errorf("ttl: entry %q missing from bucket %d", item.Key, item.ExpireBucket)
+			}
+		}
+		size += item.size
+	}
+
+	for i, b := range c.buckets {
+		var newest time.Time
+		for key, ent := range b.entries {
+			if c.items[key] != ent {
+				return fmt.Errorf("ttl: bucket %d holds %q which is not in the cache", i, key)
+			}
+			if got := ent.Value.(*Item).ExpireBucket; int(got) != i {
+				return fmt.Errorf("ttl: entry %q is in bucket %d but records bucket %d", key, i, got)
+			}
+			if at := ent.Value.(*Item).ExpiresAt; at.After(newest) {
+				newest = at
+			}
+		}
+		if !b.stale && !b.newestEntry.Equal(newest) {
+			return fmt.Errorf("ttl: bucket %d deadline is %v but its newest entry expires at %v", i, b.newestEntry, newest)
+		}
+	}
+
+	if size != c.totalSize {
+		return fmt.Errorf("ttl: total size is %d but entries sum to %d", c.totalSize, size)
+	}
+	return nil
+}
+
+// Internals describes the state of the expiry buckets. It is meant for tests
+// of the sweeper and is not part of the stable API: its fields may change
+// with the bucket implementation.
+type Internals struct {
+	NextBucket    uint8
+	BucketSizes   []int
+	NewestEntries []time.Time
+}
+
+// Internal returns a snapshot of the expiry buckets. Not part of the stable
+// API, see Internals.
+func (c *LRU) Internal() Internals {
+	c.lock()
+	defer c.mu.Unlock()
+	res := Internals{
+		NextBucket:    c.nextCleanupBucket,
+		BucketSizes:   make([]int, len(c.buckets)),
+		NewestEntries: make([]time.Time, len(c.buckets)),
+	}
+	for i := range c.buckets {
+		res.BucketSizes[i] = len(c.buckets[i].entries)
+		res.NewestEntries[i] = c.buckets[i].deadline()
+	}
+	return res
+}
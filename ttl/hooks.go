@@ -0,0 +1,138 @@
+package ttl
+
+import (
+	"io"
+	"reflect"
+)
+
+type reason uint8
+
+const (
+	reasonEvicted reason = iota
+	reasonExpired
+	reasonRemoved
+	reasonReplaced
+	reasonPurged
+	// reasonDrained entries are handed over to the caller by Drain, so
+	// their values are not closed.
+	reasonDrained
+	// reasonRolledBack entries were never successfully written, their values
+	// still belong to the caller.
+	reasonRolledBack
+)
+
+type removal struct {
+	key    string
+	value  any
+	reason reason
+}
+
+// release queues the callbacks for a value that left the cache. They are run
+// by unlock once c.mu has been released, so a callback may safely call back
+// into the cache.
+func (c *LRU) release(key string, value any, r reason) {
+	if r == reasonDrained || r == reasonRolledBack {
+		return
+	}
+	if !c.autoClose && c.finalizer == nil &&
+		(r != reasonExpired || c.onExpire == nil) && (r != reasonEvicted || c.onEvict == nil) {
+		return
+	}
+	c.removed = append(c.removed, removal{key: key, value: value, reason: r})
+}
+
+// unlock releases c.mu and then fires the callbacks queued while it was
+// held, in removal order. For each value OnExpire or OnEvict runs before
+// Close.
+func (c *LRU) unlock() {
+	removed := c.removed
+	c.removed = nil
+	flushed, flushPending := c.flushed, c.flushPending
+	c.flushPending = false
+	fullChanged := false
+	if c.onFull != nil {
+		if full := c.cap > 0 && len(c.items) >= c.cap; full != c.full {
+			c.full = full
+			fullChanged = true
+		}
+	}
+	full := c.full
+	c.mu.Unlock()
+	for _, r := range removed {
+		c.notify(r)
+	}
+	if flushPending {
+		c.protect(func() { c.onFlush(flushed) })
+	}
+	if fullChanged {
+		c.protect(func() { c.onFull(full) })
+	}
+	if c.finalizer != nil && len(removed) > 0 {
+		go c.finalize(removed)
+	}
+}
+
+func (c *LRU) finalize(removed []removal) {
+	for _, r := range removed {
+		c.protect(func() { c.finalizer(r.key, r.value) })
+	}
+}
+
+// flush records that the whole cache, count entries, is being cleared, for
+// the OnFlush callback.
+func (c *LRU) flush(count int) {
+	if c.onFlush == nil {
+		return
+	}
+	c.flushed = count
+	c.flushPending = true
+}
+
+func (c *LRU) notify(r removal) {
+	if r.reason == reasonExpired && c.onExpire != nil {
+		c.protect(func() { c.onExpire(r.key, r.value) })
+	}
+	if r.reason == reasonEvicted && c.onEvict != nil {
+		c.protect(func() { c.onEvict(r.key, r.value) })
+	}
+	if !c.autoClose {
+		return
+	}
+	closer, ok := r.value.(io.Closer)
+	if !ok {
+		return
+	}
+	c.protect(func() {
+		if err := closer.Close(); err != nil && c.onCloseErr != nil {
+			c.onCloseErr(r.key, err)
+		}
+	})
+}
+
+// protect runs fn and hands a panic to the WithPanicHandler handler instead
+// of letting it unwind through the cache. It reports whether fn panicked.
+// Without a handler panics propagate.
+func (c *LRU) protect(fn func()) (panicked bool) {
+	if c.onPanic == nil {
+		fn()
+		return false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			c.onPanic(r)
+		}
+	}()
+	fn()
+	return false
+}
+
+func sameValue(a, b any) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
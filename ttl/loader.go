@@ -0,0 +1,126 @@
+package ttl
+
+import (
+	"sync"
+	"time"
+)
+
+type call struct {
+	wg    sync.WaitGroup
+	value any
+	err   error
+}
+
+type failure struct {
+	err       error
+	expiresAt time.Time
+}
+
+// GetOrCompute returns the live value for key, or calls compute to produce
+// it and adds the result with the default TTL. Concurrent calls for the same
+// missing key share a single compute call; errors are returned to all of
+// them and, unless WithErrorTTL is set, nothing is cached. compute runs
+// without the cache lock held.
+func (c *LRU) GetOrCompute(key string, compute func() (any, error)) (any, error) {
+	return c.load(key, func() (any, time.Duration, error) {
+		value, err := compute()
+		return value, c.ttl, err
+	})
+}
+
+func (c *LRU) load(key string, load func() (any, time.Duration, error)) (any, error) {
+	c.lock()
+	now := c.now()
+	if value, ok := c.get(key, now); ok {
+		c.unlock()
+		return value, nil
+	}
+	if f, ok := c.failures[key]; ok {
+		if now.Before(f.expiresAt) {
+			c.unlock()
+			return nil, f.err
+		}
+		delete(c.failures, key)
+	}
+	if cl, ok := c.calls[key]; ok {
+		c.unlock()
+		cl.wg.Wait()
+		return c.loaded(cl)
+	}
+	cl := &call{}
+	cl.wg.Add(1)
+	if c.calls == nil {
+		c.calls = make(map[string]*call)
+	}
+	c.calls[key] = cl
+	c.unlock()
+
+	// Without a WithPanicHandler a panicking load unwinds through here; the
+	// waiters then get ErrPanicked and later calls for key load afresh.
+	done := false
+	defer func() {
+		if !done {
+			c.lock()
+			delete(c.calls, key)
+			c.unlock()
+			cl.value, cl.err = nil, ErrPanicked
+		}
+		cl.wg.Done()
+	}()
+
+	var ttl time.Duration
+	start := c.now()
+	if c.protect(func() { cl.value, ttl, cl.err = load() }) {
+		cl.value, cl.err = nil, ErrPanicked
+	}
+	done = true
+
+	c.lock()
+	delete(c.calls, key)
+	if cl.err != nil {
+		c.addFailure(key, cl.err, c.now())
+	} else if c.dropExpiredLoads && ttl > 0 && c.now().Sub(start) >= ttl {
+		cl.value, cl.err = nil, ErrLoadExpired
+	} else if !c.closed {
+		_ = c.checkedAdd(key, cl.value, ttl, c.now(), 0)
+	}
+	c.unlock()
+	return c.loaded(cl)
+}
+
+// loaded returns the result of a finished load, copied by WithCopyOnRead
+// like any other read.
+func (c *LRU) loaded(cl *call) (any, error) {
+	if cl.err == nil && c.copyOnRead != nil {
+		return c.copyOnRead(cl.value), nil
+	}
+	return cl.value, cl.err
+}
+
+// addFailure remembers a compute error for the error TTL. At most as many
+// failures as the cache capacity, or maxFailures for unbounded caches, are
+// kept; expired ones are dropped first to make room and the error isn't
+// cached if none has expired. Writing or removing the key forgets it.
+func (c *LRU) addFailure(key string, err error, now time.Time) {
+	if c.errorTTL <= 0 {
+		return
+	}
+	if c.failures == nil {
+		c.failures = make(map[string]failure)
+	}
+	limit := c.cap
+	if limit <= 0 {
+		limit = maxFailures
+	}
+	if len(c.failures) >= limit {
+		for k, f := range c.failures {
+			if !now.Before(f.expiresAt) {
+				delete(c.failures, k)
+			}
+		}
+		if len(c.failures) >= limit {
+			return
+		}
+	}
+	c.failures[key] = failure{err: err, expiresAt: now.Add(c.errorTTL)}
+}
@@ -0,0 +1,452 @@
+package ttl
+
+import "time"
+
+type Option func(*LRU)
+
+// WithEqualityFunc makes Add treat a write of a value equal to the live
+// cached one as a no-op: the value is not overwritten and the entry is not
+// promoted. The TTL is still refreshed unless WithKeepTTLOnEqual is set.
+func WithEqualityFunc(eq func(a, b any) bool) Option {
+	return func(c *LRU) {
+		c.equal = eq
+	}
+}
+
+// WithKeepTTLOnEqual skips the TTL refresh for writes detected as no-ops by
+// the WithEqualityFunc function.
+func WithKeepTTLOnEqual() Option {
+	return func(c *LRU) {
+		c.keepTTLOnEqual = true
+	}
+}
+
+type Policy uint8
+
+const (
+	// PolicyLRU promotes entries on Get and evicts the least recently used.
+	PolicyLRU Policy = iota
+	// PolicyMRU does not promote on Get and evicts the most recently used
+	// entry, which suits sequential scans larger than the cache.
+	PolicyMRU
+	// PolicyFIFO never relinks entries on access or overwrite and evicts the
+	// earliest inserted entry.
+	PolicyFIFO
+)
+
+func WithPolicy(p Policy) Option {
+	return func(c *LRU) {
+		c.policy = p
+	}
+}
+
+// WithTinyLFU enables TinyLFU admission: when the cache is full, a new key is
+// only admitted if its estimated access frequency is higher than that of the
+// entry it would evict. Otherwise Add drops the new key.
+func WithTinyLFU() Option {
+	return func(c *LRU) {
+		c.tinyLFU = true
+	}
+}
+
+// WithSketchSize sets the number of counters per row of the TinyLFU
+// frequency sketch. It defaults to four times the cache capacity.
+func WithSketchSize(width int) Option {
+	return func(c *LRU) {
+		c.sketchWidth = width
+	}
+}
+
+// WithAutoClose closes values implementing io.Closer whenever they leave the
+// cache: on eviction, expiry, Remove, RemoveOldest, Purge and when Add
+// replaces them with a different value. Close is called after the entry has
+// been detached and the cache lock released, after OnExpire for expired
+// entries.
+func WithAutoClose() Option {
+	return func(c *LRU) {
+		c.autoClose = true
+	}
+}
+
+// WithCloseErrorHandler receives the errors returned by Close in
+// WithAutoClose mode.
+func WithCloseErrorHandler(fn func(key string, err error)) Option {
+	return func(c *LRU) {
+		c.onCloseErr = fn
+	}
+}
+
+// WithOnExpire registers a callback fired for every entry removed because
+// its TTL elapsed, by the background sweeper or by SweepExpired. Removal
+// callbacks run after the cache lock is released, on the goroutine that
+// removed the entry, so they may call any method of the cache.
+func WithOnExpire(fn func(key string, value any)) Option {
+	return func(c *LRU) {
+		c.onExpire = fn
+	}
+}
+
+// WithOnEvict registers a callback fired for every entry removed to respect
+// the capacity or size limits. Like OnExpire it runs after the cache lock is
+// released.
+func WithOnEvict(fn func(key string, value any)) Option {
+	return func(c *LRU) {
+		c.onEvict = fn
+	}
+}
+
+// WithMaxSize bounds the sum of the entries' sizes, as reported by Sizer,
+// evicting entries until the total fits. An entry larger than size on its
+// own is evicted right away, firing OnEvict. The entry count is still bounded
+// by the cache capacity.
+func WithMaxSize(size int64) Option {
+	return func(c *LRU) {
+		c.maxSize = size
+	}
+}
+
+// WithSizeFunc measures entries for WithMaxSize with fn instead of the
+// Sizer interface.
+func WithSizeFunc(fn func(value any) int64) Option {
+	return func(c *LRU) {
+		c.sizeFn = fn
+	}
+}
+
+// WithCopyOnRead makes Get return clone(value) instead of the cached value,
+// so callers can't mutate the cached copy through the result.
+func WithCopyOnRead(clone func(v any) any) Option {
+	return func(c *LRU) {
+		c.copyOnRead = clone
+	}
+}
+
+// WithCopyOnWrite makes Add store clone(value), so later mutations of the
+// caller's value don't reach the cache. It disables WithWeakValues.
+func WithCopyOnWrite(clone func(v any) any) Option {
+	return func(c *LRU) {
+		c.copyOnWrite = clone
+	}
+}
+
+// WithMaxKeyLen makes Add drop keys longer than n bytes, and TryAdd reject
+// them with ErrKeyTooLong. Rejections are counted in Stats.RejectedKeys.
+func WithMaxKeyLen(n int) Option {
+	return func(c *LRU) {
+		c.maxKeyLen = n
+	}
+}
+
+// WithWriteDoesNotPromote makes overwrites of existing keys update the value
+// and TTL without moving the entry, so only reads drive its recency.
+func WithWriteDoesNotPromote() Option {
+	return func(c *LRU) {
+		c.writeNoPromote = true
+	}
+}
+
+// WithErrorTTL makes GetOrCompute remember a failed compute for d and return
+// the same error to callers during that time without calling compute again.
+// Writing or removing the key, Purge and Reset forget the error.
+func WithErrorTTL(d time.Duration) Option {
+	return func(c *LRU) {
+		c.errorTTL = d
+	}
+}
+
+// WithEvictBatchSize caps how many entries Resize evicts per lock hold. By
+// default a shrink evicts everything under a single lock hold.
+func WithEvictBatchSize(n int) Option {
+	return func(c *LRU) {
+		c.evictBatch = n
+	}
+}
+
+// WithSharedSweeper makes the cache's expiry sweeps run on m instead of a
+// goroutine of its own.
+func WithSharedSweeper(m *SweepManager) Option {
+	return func(c *LRU) {
+		c.sweeper = m
+	}
+}
+
+// WithSweeper sets how often the background sweeper processes an expiry
+// bucket, independently of the default TTL. A full lap over the buckets
+// takes 100 intervals. Without it the interval is ttl/100, or 10ms for caches
+// without a default TTL once they hold an entry added with a TTL.
+func WithSweeper(interval time.Duration) Option {
+	return func(c *LRU) {
+		c.interval = interval
+	}
+}
+
+// WithPriority makes capacity and size eviction remove the entry with the
+// lowest priority(value) instead of the least recently used one. Priorities
+// are computed on every write of the entry. Reads still update recency.
+func WithPriority(priority func(value any) int64) Option {
+	return func(c *LRU) {
+		c.priority = priority
+	}
+}
+
+// WithEagerExpiry makes reads that find an expired entry remove it right
+// away, firing OnExpire, instead of leaving it to the sweeper.
+func WithEagerExpiry() Option {
+	return func(c *LRU) {
+		c.eagerExpiry = true
+	}
+}
+
+// WithOnPromote registers a callback fired when a read or Touch moves an
+// entry to the front of the recency order. It isn't fired for entries that
+// already are the most recently used. Unlike removal callbacks it runs with
+// the cache lock held, so it must not call back into the cache.
+func WithOnPromote(fn func(key string)) Option {
+	return func(c *LRU) {
+		c.onPromote = fn
+	}
+}
+
+// WithFinalizer registers fn to be called exactly once for every value that
+// leaves the cache, whatever the reason, except values handed back to the
+// caller by Drain or TransferTo. It runs on a background goroutine after the
+// removal completed and the lock was released, so slow cleanup never delays
+// cache callers. OnExpire and Close from WithAutoClose, by contrast, run
+// synchronously on the goroutine that removed the entry. Finalizers for
+// entries removed by the same operation run in removal order; those of
+// different operations may run concurrently.
+func WithFinalizer(fn func(key string, value any)) Option {
+	return func(c *LRU) {
+		c.finalizer = fn
+	}
+}
+
+// WithWriteThrough calls fn with every key and value written to the cache,
+// right after the in-memory update and with the cache lock held. Its error
+// is returned by TryAdd; Add ignores it. The cache write is kept unless
+// WithWriteThroughRollback is set.
+func WithWriteThrough(fn func(key string, value any) error) Option {
+	return func(c *LRU) {
+		c.writeThrough = fn
+	}
+}
+
+// WithWriteThroughRollback undoes a cache write whose write-through failed:
+// a new key is removed again and an overwritten key gets its previous value,
+// TTL and recency back. Removal callbacks don't fire for the undone write.
+// Evictions caused by the write are not undone.
+func WithWriteThroughRollback() Option {
+	return func(c *LRU) {
+		c.rollback = true
+	}
+}
+
+// WithLoader turns the cache into a read-through cache: a Get that misses
+// calls load, caches its value for the returned TTL, or the cache's default
+// TTL if load returns zero, and returns it. GetWithTTL overrides both.
+// Concurrent misses for a key share one load call, as with GetOrCompute. A
+// failed load makes Get report a miss.
+func WithLoader(load func(key string) (any, time.Duration, error)) Option {
+	return func(c *LRU) {
+		c.loader = load
+	}
+}
+
+// WithNoRecency disables recency tracking: reads and overwrites never move
+// entries, so capacity eviction removes the oldest inserted entry. It is the
+// same as WithPolicy(PolicyFIFO).
+func WithNoRecency() Option {
+	return WithPolicy(PolicyFIFO)
+}
+
+// WithOnFlush registers a callback fired once per Purge or Reset with the
+// number of entries the cache held before being cleared. It runs after the
+// per-entry removal callbacks, once the cache lock is released.
+func WithOnFlush(fn func(count int)) Option {
+	return func(c *LRU) {
+		c.onFlush = fn
+	}
+}
+
+// WithPreciseExpiry replaces the bucket ring with a min-heap of deadlines and
+// a single timer armed for the earliest one, so entries are removed close to
+// their actual expiry. It suits very short TTLs, for which the granularity of
+// the buckets is too coarse, at the cost of O(log n) writes.
+func WithPreciseExpiry() Option {
+	return func(c *LRU) {
+		c.precise = true
+	}
+}
+
+// WithAdmissionSampling admits only a rate fraction of the new keys that
+// would evict an entry when the cache is full; the others are dropped by Add.
+// Writes to existing keys and adds while the cache has room are always
+// accepted. This protects the working set from floods of one-time keys at
+// almost no cost. ForceAdd bypasses the sampling.
+func WithAdmissionSampling(rate float64) Option {
+	return func(c *LRU) {
+		c.sampling = rate
+	}
+}
+
+// WithPanicHandler recovers panics from the callbacks the cache invokes:
+// removal, flush, promote and finalizer callbacks, Close of auto-closed
+// values, loaders and GetOrCompute functions, and write-through hooks.
+// The recovered value is passed to handler and the cache operation carries on:
+// a panicking loader or write-through counts as failed with ErrPanicked.
+// Functions computing values, such as equality, size, priority and clone
+// functions, are not covered.
+func WithPanicHandler(handler func(r any)) Option {
+	return func(c *LRU) {
+		c.onPanic = handler
+	}
+}
+
+// WithInitialCapacity preallocates room for n entries when the cache is
+// created. It does not change the eviction capacity. By default nothing is
+// preallocated and the maps grow as entries are added.
+func WithInitialCapacity(n int) Option {
+	return func(c *LRU) {
+		c.initialCap = max(n, 0)
+	}
+}
+
+// WithEvictionLog keeps the last size evictions and expiries, readable
+// through RecentEvictions. Older records are overwritten.
+func WithEvictionLog(size int) Option {
+	return func(c *LRU) {
+		c.evictionLog = evictionLog{records: make([]EvictionRecord, max(size, 0))}
+	}
+}
+
+// WithSweepBatch lets a sweep tick clear up to n buckets instead of one when
+// expired entries pile up. After the scheduled bucket the sweeper keeps
+// removing the expired entries of the buckets that follow it while each one
+// had some, so bursts of expiring entries are reclaimed without waiting for
+// later ticks. The sweep schedule itself is unchanged.
+func WithSweepBatch(n int) Option {
+	return func(c *LRU) {
+		c.sweepBatch = n
+	}
+}
+
+// WithRejectNil makes writes of a nil value no-ops, so Get never reports a
+// stored nil. An existing entry under the key is left as it is.
+func WithRejectNil() Option {
+	return func(c *LRU) {
+		c.rejectNil = true
+	}
+}
+
+// WithNilDeletes makes a write of a nil value remove the key instead of
+// storing nil.
+func WithNilDeletes() Option {
+	return func(c *LRU) {
+		c.rejectNil = true
+		c.nilDeletes = true
+	}
+}
+
+// WithAbsoluteLifetime makes an entry keep the deadline it got when first
+// added: overwriting a live entry, with Add or AddWithTTL, changes its value
+// but not its expiry, so no entry outlives its TTL however often it is
+// written. Touch, RenewMulti, GetAndMaybeRenew and zero Increments can't
+// extend the deadline past CreatedAt plus that TTL either. Writing a key
+// whose entry has expired or left the cache starts a new lifetime.
+func WithAbsoluteLifetime() Option {
+	return func(c *LRU) {
+		c.absoluteLifetime = true
+	}
+}
+
+// WithOnFull calls fn with true when the cache reaches its capacity and
+// with false when it drops back below it, once per transition. Caches with
+// no capacity limit never become full. Like the removal callbacks, fn runs
+// after the cache lock is released.
+func WithOnFull(fn func(full bool)) Option {
+	return func(c *LRU) {
+		c.onFull = fn
+	}
+}
+
+// WithDropExpiredLoads discards the result of a GetOrCompute or WithLoader
+// load whose TTL, counted from when the load started, ran out before the
+// load returned. Such a value is not cached: Get reports a miss and
+// GetOrCompute returns ErrLoadExpired, to the caller and to everyone who was
+// waiting on the same load.
+func WithDropExpiredLoads() Option {
+	return func(c *LRU) {
+		c.dropExpiredLoads = true
+	}
+}
+
+// WithEvictionVeto asks allow before evicting an entry to make room, for the
+// capacity and for WithMaxSize alike. If it returns false the entry stays and
+// the next candidate in eviction order is asked instead; WithTinyLFU compares
+// new keys against that candidate too. When every entry is vetoed the usual
+// victim is evicted anyway, so the limits are always honoured. Expiry is not
+// subject to the veto. Like WithOnPromote, allow runs with the cache lock
+// held and must not call back into the cache; asking every entry makes a
+// fully vetoed eviction O(n).
+func WithEvictionVeto(allow func(key string, value any) bool) Option {
+	return func(c *LRU) {
+		c.evictionVeto = allow
+	}
+}
+
+// WithSweepAlignment starts the sweeper on a multiple of d since the zero
+// time, such as a whole second for d = time.Second, and ticks every sweep
+// interval from there. Caches whose interval divides d thus sweep at the same
+// moments. Only the phase of the sweeps changes, not how often they run or
+// what they remove.
+func WithSweepAlignment(d time.Duration) Option {
+	return func(c *LRU) {
+		c.sweepAlign = d
+	}
+}
+
+// WithMetricsReporter calls fn with the cache's Stats every interval from a
+// goroutine of its own, until Close is called.
+func WithMetricsReporter(interval time.Duration, fn func(Stats)) Option {
+	return func(c *LRU) {
+		c.reportEvery = interval
+		c.report = fn
+	}
+}
+
+// WithClock makes the cache read the time from clock instead of time.Now
+// when stamping entries and deciding whether they have expired, so tests can
+// move time forward by hand. The sweeper and other timers still run on real
+// time: with a clock that runs ahead, expired entries are no longer returned
+// but are only swept when the sweeper next visits their bucket, or by
+// SweepExpired.
+func WithClock(clock Clock) Option {
+	return func(c *LRU) {
+		c.clock = clock
+	}
+}
+
+// WithMaxBucketSize limits the sweeper's buckets to n entries, bounding how
+// long a single sweep holds the lock. An entry that would go into a full
+// bucket goes into the next one with room instead, so it is swept one or
+// more sweep intervals after it expires, up to a full lap of the ring when
+// most buckets are full. Expired entries are never returned by reads either
+// way. Once every bucket is full the limit no longer applies.
+func WithMaxBucketSize(n int) Option {
+	return func(c *LRU) {
+		c.maxBucketSize = n
+	}
+}
+
+// WithWeakValues holds pointer values through weak pointers, so the garbage
+// collector may reclaim a value that nothing outside the cache references.
+// An entry whose value was collected counts as expired: reads miss and
+// remove it, and removal callbacks get a nil value. Values other than
+// pointers are held as usual. Weak pointers need Go 1.24; built with an
+// older Go, the option has no effect. It has no effect with WithCopyOnWrite
+// either, since nothing outside the cache references the stored copies.
+func WithWeakValues() Option {
+	return func(c *LRU) {
+		c.weakValues = true
+	}
+}
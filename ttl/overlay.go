@@ -0,0 +1,72 @@
+package ttl
+
+import "sync"
+
+// Overlay buffers writes on top of a base cache. Reads see the buffered
+// writes first and fall through to the base cache; nothing reaches the base
+// cache until Commit.
+type Overlay struct {
+	base *LRU
+
+	mu     sync.Mutex
+	writes map[string]any
+	order  []string
+}
+
+func (c *LRU) Overlay() *Overlay {
+	return &Overlay{
+		base:   c,
+		writes: make(map[string]any),
+	}
+}
+
+func (o *Overlay) Get(key string) (any, bool) {
+	o.mu.Lock()
+	value, ok := o.writes[key]
+	o.mu.Unlock()
+	if ok {
+		return value, true
+	}
+	return o.base.Get(key)
+}
+
+func (o *Overlay) Set(key string, value any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.writes[key]; !ok {
+		o.order = append(o.order, key)
+	}
+	o.writes[key] = value
+}
+
+// Commit adds the buffered writes to the base cache in the order the keys
+// were first written, under a single hold of the base cache lock, and
+// empties the overlay.
+func (o *Overlay) Commit() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	c := o.base
+	c.lock()
+	if !c.closed {
+		now := c.now()
+		for _, key := range o.order {
+			_ = c.checkedAdd(key, o.writes[key], c.ttl, now, 0)
+		}
+	}
+	c.unlock()
+
+	o.discard()
+}
+
+// Discard drops the buffered writes.
+func (o *Overlay) Discard() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.discard()
+}
+
+func (o *Overlay) discard() {
+	clear(o.writes)
+	o.order = o.order[:0]
+}
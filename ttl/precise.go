@@ -0,0 +1,76 @@
+package ttl
+
+import (
+	"container/heap"
+	"container/list"
+	"time"
+)
+
+// expiryQueue is a min-heap of the expiring entries ordered by ExpiresAt,
+// used instead of the bucket ring in WithPreciseExpiry mode.
+type expiryQueue []*list.Element
+
+func (q expiryQueue) Len() int { return len(q) }
+
+func (q expiryQueue) Less(i, j int) bool {
+	return q[i].Value.(*Item).ExpiresAt.Before(q[j].Value.(*Item).ExpiresAt)
+}
+
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].Value.(*Item).expiryIndex = i
+	q[j].Value.(*Item).expiryIndex = j
+}
+
+func (q *expiryQueue) Push(x any) {
+	e := x.(*list.Element)
+	e.Value.(*Item).expiryIndex = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return e
+}
+
+func (c *LRU) addToExpiryQueue(e *list.Element) {
+	heap.Push(&c.expiryQueue, e)
+	if e.Value.(*Item).expiryIndex == 0 {
+		c.scheduleExpiry()
+	}
+}
+
+func (c *LRU) removeFromExpiryQueue(e *list.Element) {
+	heap.Remove(&c.expiryQueue, e.Value.(*Item).expiryIndex)
+}
+
+// scheduleExpiry arms the expiry timer for the earliest deadline. The timer
+// may fire for an entry that was removed meanwhile; expireDue then just
+// schedules the next deadline.
+func (c *LRU) scheduleExpiry() {
+	if !c.started || c.closed || len(c.expiryQueue) == 0 {
+		return
+	}
+	d := c.expiryQueue[0].Value.(*Item).ExpiresAt.Sub(c.now())
+	if c.expiryTimer == nil {
+		c.expiryTimer = time.AfterFunc(d, c.expireDue)
+		return
+	}
+	c.expiryTimer.Reset(d)
+}
+
+// expireDue removes the entries whose deadline has been reached. The timer
+// fires at the deadline itself, when expired doesn't count the entry as
+// expired yet, so waiting for that would keep re-arming it with no delay.
+func (c *LRU) expireDue() {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	for len(c.expiryQueue) > 0 && !now.Before(c.expiryQueue[0].Value.(*Item).ExpiresAt) {
+		c.removeElement(c.expiryQueue[0], reasonExpired)
+	}
+	c.scheduleExpiry()
+}
@@ -0,0 +1,101 @@
+package ttl
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPreciseExpiryAtDeadline(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	var expired []string
+	c := NewLRU(10, 0, WithClock(clock), WithPreciseExpiry(), WithOnExpire(func(key string, _ any) {
+		expired = append(expired, key)
+	}))
+	defer c.Close()
+
+	c.AddWithTTL("a", 1, time.Minute)
+	c.AddWithTTL("b", 1, 2*time.Minute)
+	clock.Advance(time.Minute)
+	c.expireDue()
+	if want := []string{"a"}; !slices.Equal(expired, want) {
+		t.Fatalf("expired at a's deadline = %v, want %v", expired, want)
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("entry removed before its deadline")
+	}
+}
+
+func TestPreciseExpiryRemoveAndUpdate(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	var expired []string
+	c := NewLRU(10, 0, WithClock(clock), WithPreciseExpiry(), WithOnExpire(func(key string, _ any) {
+		expired = append(expired, key)
+	}))
+	defer c.Close()
+
+	c.AddWithTTL("removed", 1, time.Minute)
+	c.AddWithTTL("updated", 1, time.Minute)
+	c.Remove("removed")
+	c.Update("updated", 2, 3*time.Minute)
+	if n := len(c.expiryQueue); n != 1 {
+		t.Fatalf("expiry queue holds %d entries, want 1", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	c.expireDue()
+	if len(expired) != 0 {
+		t.Fatalf("expired %v at their old deadlines", expired)
+	}
+	clock.Advance(time.Minute)
+	c.expireDue()
+	if want := []string{"updated"}; !slices.Equal(expired, want) {
+		t.Fatalf("expired = %v, want %v", expired, want)
+	}
+	if n := len(c.expiryQueue); n != 0 {
+		t.Fatalf("expiry queue holds %d entries after expiry", n)
+	}
+}
+
+func TestPreciseExpiryReschedulesEarlierDeadline(t *testing.T) {
+	var mu sync.Mutex
+	var expired []string
+	c := NewLRU(10, 0, WithPreciseExpiry(), WithOnExpire(func(key string, _ any) {
+		mu.Lock()
+		expired = append(expired, key)
+		mu.Unlock()
+	}))
+	defer c.Close()
+
+	c.AddWithTTL("late", 1, time.Hour)
+	c.AddWithTTL("early", 1, 10*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for c.Len() == 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"early"}; !slices.Equal(expired, want) {
+		t.Fatalf("expired = %v, want %v", expired, want)
+	}
+}
+
+func TestPreciseExpiryStopsOnClose(t *testing.T) {
+	var mu sync.Mutex
+	var expired []string
+	c := NewLRU(10, 0, WithPreciseExpiry(), WithOnExpire(func(key string, _ any) {
+		mu.Lock()
+		expired = append(expired, key)
+		mu.Unlock()
+	}))
+
+	c.AddWithTTL("k", 1, 10*time.Millisecond)
+	c.Close()
+	time.Sleep(30 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expired) != 0 || c.Len() != 1 {
+		t.Fatalf("timer fired after Close: expired %v, Len %d", expired, c.Len())
+	}
+}
@@ -0,0 +1,78 @@
+package ttl
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// priorityQueue is a min-heap of entries ordered by the priority computed by
+// the WithPriority function.
+type priorityQueue []*list.Element
+
+func (pq priorityQueue) Len() int { return len(pq) }
+
+func (pq priorityQueue) Less(i, j int) bool {
+	return pq[i].Value.(*Item).priority < pq[j].Value.(*Item).priority
+}
+
+func (pq priorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].Value.(*Item).heapIndex = i
+	pq[j].Value.(*Item).heapIndex = j
+}
+
+func (pq *priorityQueue) Push(x any) {
+	e := x.(*list.Element)
+	e.Value.(*Item).heapIndex = len(*pq)
+	*pq = append(*pq, e)
+}
+
+func (pq *priorityQueue) Pop() any {
+	old := *pq
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*pq = old[:len(old)-1]
+	return e
+}
+
+func (c *LRU) updatePriority(e *list.Element, added bool) {
+	if c.priority == nil {
+		return
+	}
+	item := e.Value.(*Item)
+	item.priority = c.priority(item.value())
+	if added {
+		heap.Push(&c.pq, e)
+	} else {
+		heap.Fix(&c.pq, item.heapIndex)
+	}
+}
+
+func (c *LRU) removePriority(e *list.Element) {
+	if c.priority == nil {
+		return
+	}
+	heap.Remove(&c.pq, e.Value.(*Item).heapIndex)
+}
+
+// victimExcept returns the entry to evict other than keep.
+func (c *LRU) victimExcept(keep *list.Element) *list.Element {
+	ent := c.victim()
+	if ent == nil || ent != keep {
+		return ent
+	}
+	switch {
+	case c.priority != nil:
+		ent = nil
+		for _, i := range []int{1, 2} {
+			if i < len(c.pq) && (ent == nil || c.pq.Less(i, ent.Value.(*Item).heapIndex)) {
+				ent = c.pq[i]
+			}
+		}
+		return ent
+	case c.policy == PolicyMRU:
+		return ent.Next()
+	default:
+		return ent.Prev()
+	}
+}
@@ -0,0 +1,78 @@
+package ttl
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// ShardedLRU spreads keys over several independent LRU caches to reduce lock
+// contention. A key always maps to the same shard, so per-key guarantees of
+// LRU, such as the deduplication of GetOrCompute, hold for the whole cache.
+type ShardedLRU struct {
+	shards []*LRU
+}
+
+// NewShardedLRU creates a cache of n shards sharing cap evenly between them.
+// Each shard gets its own copy of opts and its own sweeper.
+func NewShardedLRU(n, cap int, ttl time.Duration, opts ...Option) *ShardedLRU {
+	if n < 1 {
+		n = 1
+	}
+	perShard := 0
+	if cap > 0 {
+		perShard = (cap + n - 1) / n
+	}
+
+	res := &ShardedLRU{shards: make([]*LRU, n)}
+	for i := range res.shards {
+		res.shards[i] = NewLRU(perShard, ttl, opts...)
+	}
+	return res
+}
+
+func (c *ShardedLRU) shard(key string) *LRU {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+func (c *ShardedLRU) Add(key string, value any) {
+	c.shard(key).Add(key, value)
+}
+
+func (c *ShardedLRU) Get(key string) (any, bool) {
+	return c.shard(key).Get(key)
+}
+
+// Lookup is the same as Get, see LRU.Lookup.
+func (c *ShardedLRU) Lookup(key string) (any, bool) {
+	return c.Get(key)
+}
+
+func (c *ShardedLRU) GetOrCompute(key string, compute func() (any, error)) (any, error) {
+	return c.shard(key).GetOrCompute(key, compute)
+}
+
+func (c *ShardedLRU) Remove(key string) bool {
+	return c.shard(key).Remove(key)
+}
+
+func (c *ShardedLRU) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+func (c *ShardedLRU) Purge() {
+	for _, s := range c.shards {
+		s.Purge()
+	}
+}
+
+func (c *ShardedLRU) Close() {
+	for _, s := range c.shards {
+		s.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package ttl
+
+import "hash/fnv"
+
+const sketchDepth = 4
+
+// sketch is a count-min sketch with 8-bit saturating counters. Counters are
+// halved once the number of recorded accesses reaches ten times the width so
+// that the estimates follow shifts in popularity.
+type sketch struct {
+	rows      [sketchDepth][]uint8
+	width     uint64
+	additions int
+	resetAt   int
+}
+
+func newSketch(width int) *sketch {
+	if width < 16 {
+		width = 16
+	}
+	s := &sketch{
+		width:   uint64(width),
+		resetAt: width * 10,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) add(key string) {
+	h1, h2 := sketchHash(key)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) % s.width
+		if s.rows[i][idx] < 255 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+func (s *sketch) estimate(key string) uint8 {
+	h1, h2 := sketchHash(key)
+	min := uint8(255)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) % s.width
+		if s.rows[i][idx] < min {
+			min = s.rows[i][idx]
+		}
+	}
+	return min
+}
+
+func (s *sketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+func sketchHash(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum, sum>>32 | 1
+}
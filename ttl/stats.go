@@ -0,0 +1,105 @@
+package ttl
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type Stats struct {
+	Hits         uint64
+	Misses       uint64
+	Evictions    uint64
+	Expirations  uint64
+	RejectedKeys uint64
+
+	// Sweeps counts the buckets processed by the background sweeper.
+	// TotalSweepDuration is the time spent clearing them and TotalSweepSleep
+	// the time spent waiting for their newest entry to expire.
+	Sweeps             uint64
+	TotalSweepDuration time.Duration
+	AvgSweepDuration   time.Duration
+	TotalSweepSleep    time.Duration
+}
+
+func (s Stats) add(o Stats) Stats {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Evictions += o.Evictions
+	s.Expirations += o.Expirations
+	s.RejectedKeys += o.RejectedKeys
+	s.Sweeps += o.Sweeps
+	s.TotalSweepDuration += o.TotalSweepDuration
+	s.TotalSweepSleep += o.TotalSweepSleep
+	s.AvgSweepDuration = 0
+	if s.Sweeps > 0 {
+		s.AvgSweepDuration = s.TotalSweepDuration / time.Duration(s.Sweeps)
+	}
+	return s
+}
+
+type counters struct {
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	evictions    atomic.Uint64
+	expirations  atomic.Uint64
+	rejectedKeys atomic.Uint64
+
+	sweeps     atomic.Uint64
+	sweepNanos atomic.Int64
+	sleepNanos atomic.Int64
+}
+
+// Stats returns a snapshot of the cache counters. It reads atomics only and
+// doesn't take the cache lock.
+func (c *LRU) Stats() Stats {
+	s := Stats{
+		Hits:         c.stats.hits.Load(),
+		Misses:       c.stats.misses.Load(),
+		Evictions:    c.stats.evictions.Load(),
+		Expirations:  c.stats.expirations.Load(),
+		RejectedKeys: c.stats.rejectedKeys.Load(),
+
+		Sweeps:             c.stats.sweeps.Load(),
+		TotalSweepDuration: time.Duration(c.stats.sweepNanos.Load()),
+		TotalSweepSleep:    time.Duration(c.stats.sleepNanos.Load()),
+	}
+	if s.Sweeps > 0 {
+		s.AvgSweepDuration = s.TotalSweepDuration / time.Duration(s.Sweeps)
+	}
+	return s
+}
+
+// Stats returns the sum of the shards' counters. Each shard is read
+// independently, without holding the shard locks.
+func (c *ShardedLRU) Stats() Stats {
+	var total Stats
+	for _, s := range c.shards {
+		total = total.add(s.Stats())
+	}
+	return total
+}
+
+// ShardStats returns the counters of each shard, in shard order.
+func (c *ShardedLRU) ShardStats() []Stats {
+	res := make([]Stats, len(c.shards))
+	for i, s := range c.shards {
+		res[i] = s.Stats()
+	}
+	return res
+}
+
+// reportStats passes the stats to the WithMetricsReporter function every
+// interval until done is closed.
+func (c *LRU) reportStats(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			stats := c.Stats()
+			c.protect(func() { c.report(stats) })
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ttl
+
+import "context"
+
+// Stream sends the live entries on the returned channel, from the most to
+// the least recently used, and closes it when done or when ctx is canceled.
+// Only the keys are collected up front; each entry is looked up again right
+// before it is sent, without the lock held while sending, so a slow reader
+// doesn't block the cache. Entries removed or expired in the meantime are
+// skipped. Streaming doesn't change recency or the hit counters.
+func (c *LRU) Stream(ctx context.Context) <-chan Item {
+	c.lock()
+	keys := make([]string, 0, len(c.items))
+	for ent := c.queue.Front(); ent != nil; ent = ent.Next() {
+		keys = append(keys, ent.Value.(*Item).Key)
+	}
+	c.mu.Unlock()
+
+	ch := make(chan Item)
+	go func() {
+		defer close(ch)
+		for _, key := range keys {
+			c.lock()
+			ent, ok := c.items[key]
+			var item Item
+			if ok {
+				item = *ent.Value.(*Item)
+				ok = !item.expired(c.now())
+			}
+			c.mu.Unlock()
+			if w, weak := item.Value.(weakRef); ok && weak {
+				item.Value, ok = w.get()
+			}
+			if !ok {
+				continue
+			}
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,132 @@
+package ttl
+
+import (
+	"sync"
+	"time"
+)
+
+// SweepManager runs the expiry sweeps of many caches from one goroutine,
+// instead of one goroutine per cache. Caches opt in with WithSharedSweeper
+// and are deregistered by their Close.
+type SweepManager struct {
+	mu     sync.Mutex
+	caches map[*LRU]time.Time
+	wake   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewSweepManager() *SweepManager {
+	m := &SweepManager{
+		caches: make(map[*LRU]time.Time),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// Close stops the manager goroutine. Registered caches are no longer swept.
+func (m *SweepManager) Close() {
+	m.once.Do(func() {
+		close(m.done)
+	})
+}
+
+func (m *SweepManager) register(c *LRU) {
+	m.mu.Lock()
+	m.caches[c] = c.firstSweep(time.Now())
+	m.mu.Unlock()
+	m.notify()
+}
+
+func (m *SweepManager) unregister(c *LRU) {
+	m.mu.Lock()
+	delete(m.caches, c)
+	m.mu.Unlock()
+	m.notify()
+}
+
+func (m *SweepManager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *SweepManager) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		now := time.Now()
+		due := make(map[*LRU]time.Time)
+		next := now.Add(time.Hour)
+		m.mu.Lock()
+		for c, at := range m.caches {
+			if !at.After(now) {
+				due[c] = at
+			} else if at.Before(next) {
+				next = at
+			}
+		}
+		m.mu.Unlock()
+
+		for c, at := range due {
+			at = c.sweepDue(now, at)
+			m.mu.Lock()
+			if _, ok := m.caches[c]; ok {
+				m.caches[c] = at
+				if at.Before(next) {
+					next = at
+				}
+			}
+			m.mu.Unlock()
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Until(next))
+		select {
+		case <-m.done:
+			return
+		case <-m.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// firstSweep returns when the first sweep after now is due: one interval
+// later, or with WithSweepAlignment the closest multiple of the alignment at
+// or before that, but after now.
+func (c *LRU) firstSweep(now time.Time) time.Time {
+	at := now.Add(c.interval)
+	if c.sweepAlign <= 0 {
+		return at
+	}
+	at = at.Truncate(c.sweepAlign)
+	if !at.After(now) {
+		at = at.Add(c.sweepAlign)
+	}
+	return at
+}
+
+// sweepDue is the non-blocking counterpart of deleteExpired used by the
+// SweepManager for a sweep scheduled at. If the next bucket's newest entry
+// expires within the current tick it leaves the bucket alone and asks to be
+// called again at that time; otherwise it returns the next tick.
+func (c *LRU) sweepDue(now, at time.Time) time.Time {
+	c.lock()
+	clock := c.now()
+	newest := c.buckets[c.nextCleanupBucket].deadline()
+	if len(c.buckets[c.nextCleanupBucket].entries) > 0 && newest.After(clock) && newest.Sub(clock) < c.interval {
+		c.mu.Unlock()
+		return now.Add(newest.Sub(clock))
+	}
+	c.sweepBucket(clock)
+	c.unlock()
+	return at.Add(c.interval)
+}
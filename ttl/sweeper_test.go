@@ -0,0 +1,60 @@
+package ttl
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSweepManagerServesSeveralCaches(t *testing.T) {
+	m := NewSweepManager()
+	defer m.Close()
+
+	var expired atomic.Int32
+	onExpire := WithOnExpire(func(string, any) { expired.Add(1) })
+	caches := []*LRU{
+		NewLRU(10, 10*time.Millisecond, WithSharedSweeper(m), onExpire),
+		NewLRU(10, 20*time.Millisecond, WithSharedSweeper(m), onExpire),
+		NewLRU(10, 0, WithSharedSweeper(m), onExpire),
+	}
+	for _, c := range caches {
+		c.AddWithTTL("k", 1, 15*time.Millisecond)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for expired.Load() < int32(len(caches)) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i, c := range caches {
+		if n := c.Len(); n != 0 {
+			t.Errorf("cache %d holds %d entries after its TTL, want 0", i, n)
+		}
+	}
+
+	for _, c := range caches {
+		c.Close()
+	}
+	m.mu.Lock()
+	n := len(m.caches)
+	m.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("%d caches still registered after Close", n)
+	}
+}
+
+func TestClosedCacheDoesNotRegister(t *testing.T) {
+	m := NewSweepManager()
+	defer m.Close()
+
+	c := NewLRU(10, 0, WithSharedSweeper(m))
+	c.Add("k", 1)
+	c.Close()
+	c.RenewMulti([]string{"k"}, time.Minute)
+	c.Touch("k")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.caches[c]; ok {
+		t.Fatal("closed cache registered with the SweepManager")
+	}
+}
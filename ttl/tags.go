@@ -0,0 +1,63 @@
+package ttl
+
+import "container/list"
+
+// AddWithTags adds an entry like Add and labels it with tags, replacing the
+// tags of an existing entry. InvalidateTag removes all entries with a tag.
+func (c *LRU) AddWithTags(key string, value any, tags ...string) {
+	c.lock()
+	defer c.unlock()
+	if c.closed {
+		return
+	}
+	if c.checkedAdd(key, value, c.ttl, c.now(), 0) != nil {
+		return
+	}
+	if ent, ok := c.items[key]; ok {
+		c.untag(ent)
+		c.tag(ent, tags)
+	}
+}
+
+// InvalidateTag removes every entry labeled with tag and returns how many
+// were removed.
+func (c *LRU) InvalidateTag(tag string) int {
+	c.lock()
+	defer c.unlock()
+	n := 0
+	for key := range c.tags[tag] {
+		c.removeElement(c.items[key], reasonRemoved)
+		n++
+	}
+	return n
+}
+
+func (c *LRU) tag(e *list.Element, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if c.tags == nil {
+		c.tags = make(map[string]map[string]struct{})
+	}
+	item := e.Value.(*Item)
+	item.tags = tags
+	for _, t := range tags {
+		keys, ok := c.tags[t]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[t] = keys
+		}
+		keys[item.Key] = struct{}{}
+	}
+}
+
+func (c *LRU) untag(e *list.Element) {
+	item := e.Value.(*Item)
+	for _, t := range item.tags {
+		delete(c.tags[t], item.Key)
+		if len(c.tags[t]) == 0 {
+			delete(c.tags, t)
+		}
+	}
+	item.tags = nil
+}
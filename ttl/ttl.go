@@ -2,6 +2,12 @@ package ttl
 
 import (
 	"container/list"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
@@ -10,31 +16,224 @@ type Item struct {
 	Key   string
 	Value any
 
+	CreatedAt    time.Time
 	ExpiresAt    time.Time
 	ExpireBucket uint8
+	Version      uint64
+	Accesses     uint64
+
+	ttl         time.Duration
+	size        int64
+	priority    int64
+	heapIndex   int
+	expiryIndex int
+	tags        []string
+}
+
+// expired reports whether the entry is past its deadline or its weakly held
+// value has been collected.
+func (i *Item) expired(now time.Time) bool {
+	return !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt) || i.collected()
+}
+
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// Sizer is implemented by values that report their own size for caches
+// created with WithMaxSize. Values that don't implement it count as 1.
+type Sizer interface {
+	Size() int64
 }
 
+// LRU is a size-bounded cache whose entries also expire after a TTL. The
+// zero value is an empty cache with no capacity limit and no default TTL;
+// NewLRU creates a configured one.
 type LRU struct {
-	cap   int
-	queue *list.List
-	items map[string]*list.Element
+	cap        int
+	opts       []Option
+	initialCap int
+	queue      *list.List
+	items      map[string]*list.Element
+
+	once     sync.Once
+	mu       sync.Mutex
+	clock    Clock
+	ttl      time.Duration
+	interval time.Duration
+	done     chan struct{}
+	closed   bool
+	started  bool
+	sweeper  *SweepManager
 
-	mu   sync.Mutex
-	ttl  time.Duration
-	done chan struct{}
+	expireAll *time.Timer
 
 	buckets           []bucket
 	nextCleanupBucket uint8
+
+	equal            func(a, b any) bool
+	keepTTLOnEqual   bool
+	absoluteLifetime bool
+	policy           Policy
+	writeNoPromote   bool
+	eagerExpiry      bool
+	onPromote        func(key string)
+	evictionVeto     func(key string, value any) bool
+
+	tinyLFU     bool
+	sketchWidth int
+	sketch      *sketch
+	sampling    float64
+
+	autoClose  bool
+	onCloseErr func(key string, err error)
+
+	onExpire  func(key string, value any)
+	onEvict   func(key string, value any)
+	finalizer func(key string, value any)
+	removed   []removal
+
+	onPanic func(r any)
+
+	onFlush      func(count int)
+	flushed      int
+	flushPending bool
+
+	onFull func(full bool)
+	full   bool
+
+	maxSize   int64
+	totalSize int64
+	sizeFn    func(value any) int64
+
+	copyOnRead  func(v any) any
+	copyOnWrite func(v any) any
+	weakValues  bool
+
+	maxKeyLen   int
+	rejectNil   bool
+	nilDeletes  bool
+	stats       counters
+	evictionLog evictionLog
+	report      func(Stats)
+	reportEvery time.Duration
+
+	evictBatch int
+	sweepBatch int
+	sweepAlign time.Duration
+
+	maxBucketSize int
+
+	priority func(value any) int64
+	pq       priorityQueue
+
+	tags map[string]map[string]struct{}
+
+	writeThrough func(key string, value any) error
+	rollback     bool
+
+	precise     bool
+	expiryQueue expiryQueue
+	expiryTimer *time.Timer
+
+	calls    map[string]*call
+	loader   func(key string) (any, time.Duration, error)
+	errorTTL time.Duration
+	failures map[string]failure
+
+	dropExpiredLoads bool
 }
 
 type bucket struct {
 	entries     map[string]*list.Element
 	newestEntry time.Time
+	// stale is set when the entry with the newest deadline left the bucket,
+	// newestEntry is then recomputed by deadline before it is used.
+	stale bool
 }
 
-const numBuckets = 100
+// deadline returns the latest expiry of the bucket's entries.
+func (b *bucket) deadline() time.Time {
+	if b.stale {
+		b.newestEntry = time.Time{}
+		for _, ent := range b.entries {
+			if at := ent.Value.(*Item).ExpiresAt; at.After(b.newestEntry) {
+				b.newestEntry = at
+			}
+		}
+		b.stale = false
+	}
+	return b.newestEntry
+}
+
+const (
+	numBuckets = 100
+
+	// defaultSweepInterval is the sweep cadence of caches that have no default
+	// TTL and no WithSweeper option but hold entries added with a TTL.
+	defaultSweepInterval = time.Second / numBuckets
+
+	// maxFailures bounds the failures remembered by WithErrorTTL for caches
+	// without a capacity.
+	maxFailures = 1024
+)
+
+var (
+	ErrClosed     = errors.New("ttl: cache is closed")
+	ErrKeyTooLong = errors.New("ttl: key is too long")
+
+	ErrPanicked    = errors.New("ttl: callback panicked")
+	ErrLoadExpired = errors.New("ttl: loaded value expired while loading")
+
+	ErrInvalidCapacity = errors.New("ttl: negative capacity")
+	ErrInvalidTTL      = errors.New("ttl: negative ttl")
+)
+
+func NewLRU(cap int, ttl time.Duration, opts ...Option) *LRU {
+	res := newLRU(cap, ttl, opts)
+	res.startSweeper()
+	return res
+}
+
+// NewLRUStrict is like NewLRU but rejects a negative cap or ttl instead of
+// treating them as zero.
+func NewLRUStrict(cap int, ttl time.Duration, opts ...Option) (*LRU, error) {
+	if cap < 0 {
+		return nil, ErrInvalidCapacity
+	}
+	if ttl < 0 {
+		return nil, ErrInvalidTTL
+	}
+	return NewLRU(cap, ttl, opts...), nil
+}
+
+// NewLRU2 creates a cache bounded both by maxEntries entries and by maxBytes
+// total bytes as measured by sizeFn. Adds evict until both limits hold.
+func NewLRU2(maxEntries int, maxBytes int64, sizeFn func(any) int64, ttl time.Duration, opts ...Option) *LRU {
+	opts = append([]Option{WithMaxSize(maxBytes), WithSizeFunc(sizeFn)}, opts...)
+	return NewLRU(maxEntries, ttl, opts...)
+}
+
+// NewLRUFromFunc builds a cache and fills it by calling seed before the cache
+// is returned and before its sweeper starts. The add callback inserts entries
+// like Add does and must not be retained after seed returns. Callbacks for
+// entries evicted while seeding, and WithOnFull, run before NewLRUFromFunc
+// returns.
+func NewLRUFromFunc(cap int, ttl time.Duration, seed func(add func(k string, v any)), opts ...Option) *LRU {
+	res := newLRU(cap, ttl, opts)
+	res.lock()
+	seed(func(k string, v any) {
+		_ = res.checkedAdd(k, v, res.ttl, res.now(), 0)
+	})
+	res.unlock()
+	res.startSweeper()
+	return res
+}
 
-func NewLRU(cap int, ttl time.Duration) *LRU {
+func newLRU(cap int, ttl time.Duration, opts []Option) *LRU {
 	if cap < 0 {
 		cap = 0
 	}
@@ -45,142 +244,1294 @@ func NewLRU(cap int, ttl time.Duration) *LRU {
 
 	res := &LRU{
 		cap:   cap,
-		items: make(map[string]*list.Element),
 		queue: list.New(),
+		opts:  opts,
 
-		ttl:  ttl,
-		done: make(chan struct{}),
+		ttl:      ttl,
+		interval: ttl / numBuckets,
+		done:     make(chan struct{}),
 	}
 
-	res.buckets = make([]bucket, numBuckets)
-	for i := 0; i < numBuckets; i++ {
-		res.buckets[i] = bucket{entries: make(map[string]*list.Element)}
-	}
-
-	if res.ttl != 0 {
-		go func(done <-chan struct{}) {
-			ticker := time.NewTicker(res.ttl / numBuckets)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-done:
-					return
-				case <-ticker.C:
-					res.deleteExpired()
-				}
-			}
-		}(res.done)
+	for _, opt := range opts {
+		opt(res)
+	}
+
+	if res.interval < 0 {
+		res.interval = 0
+	}
+	if res.copyOnWrite != nil {
+		// Nothing but the cache references its copies, so weak pointers to
+		// them would be collected right away.
+		res.weakValues = false
+	}
+
+	res.items = make(map[string]*list.Element, res.initialCap)
+
+	if res.tinyLFU {
+		width := res.sketchWidth
+		if width == 0 {
+			width = 4 * res.cap
+		}
+		res.sketch = newSketch(width)
 	}
 
+	res.buckets = newBuckets(res.initialCap / numBuckets)
+
 	return res
 }
 
-func (c *LRU) Purge() {
+func newBuckets(size int) []bucket {
+	buckets := make([]bucket, numBuckets)
+	for i := range buckets {
+		buckets[i] = bucket{entries: make(map[string]*list.Element, size)}
+	}
+	return buckets
+}
+
+// lock acquires c.mu, first setting up a zero LRU on its first use.
+func (c *LRU) lock() {
+	c.once.Do(c.initZero)
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	for k := range c.items {
+}
+
+// initZero makes a zero LRU usable. Caches built by NewLRU are left alone.
+func (c *LRU) initZero() {
+	if c.queue != nil {
+		return
+	}
+	c.queue = list.New()
+	c.items = make(map[string]*list.Element)
+	c.done = make(chan struct{})
+	c.buckets = newBuckets(0)
+	c.started = true
+}
+
+// startSweeper is called once the cache is ready to be used. Caches whose
+// entries never expire have no sweeper until the first expiring entry is
+// added, see addToBucket.
+func (c *LRU) startSweeper() {
+	c.started = true
+	if c.reportEvery > 0 && c.report != nil {
+		go c.reportStats(c.done, c.reportEvery)
+	}
+	if c.precise {
+		c.scheduleExpiry()
+		return
+	}
+	if c.interval > 0 {
+		c.launchSweeper()
+	}
+}
+
+func (c *LRU) launchSweeper() {
+	if c.closed {
+		return
+	}
+	if c.sweeper != nil {
+		c.sweeper.register(c)
+		return
+	}
+	go func(done <-chan struct{}, interval time.Duration) {
+		if c.sweepAlign > 0 {
+			start := time.NewTimer(time.Until(c.firstSweep(time.Now())))
+			select {
+			case <-done:
+				start.Stop()
+				return
+			case <-start.C:
+				c.deleteExpired()
+			}
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				c.deleteExpired()
+			}
+		}
+	}(c.done, c.interval)
+}
+
+// Purge removes all entries. List elements are only reachable through
+// c.items and are never used outside c.mu, so every concurrent operation
+// runs entirely before or entirely after a Purge and can't act on an element
+// detached by it.
+func (c *LRU) Purge() {
+	c.lock()
+	defer c.unlock()
+	c.flush(len(c.items))
+	for k, ent := range c.items {
+		c.release(k, ent.Value.(*Item).value(), reasonPurged)
 		delete(c.items, k)
 	}
-	for _, b := range c.buckets {
-		for _, ent := range b.entries {
-			delete(b.entries, ent.Value.(*Item).Key)
+	for i := range c.buckets {
+		for _, ent := range c.buckets[i].entries {
+			delete(c.buckets[i].entries, ent.Value.(*Item).Key)
 		}
+		c.buckets[i].newestEntry = time.Time{}
+		c.buckets[i].stale = false
 	}
 	c.queue = list.New()
+	c.totalSize = 0
+	c.pq = nil
+	c.tags = nil
+	c.expiryQueue = nil
+	c.failures = nil
 }
 
-func (c *LRU) Add(key string, value any) {
-	c.mu.Lock()
+// Close stops the background sweeper. Afterwards Add is a no-op and TryAdd
+// returns ErrClosed, so no entry can be inserted that would never be swept.
+// Reads and removals keep working on the remaining entries.
+func (c *LRU) Close() {
+	c.lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.done)
+	if c.sweeper != nil {
+		c.sweeper.unregister(c)
+	}
+	if c.expireAll != nil {
+		c.expireAll.Stop()
+	}
+	if c.expiryTimer != nil {
+		c.expiryTimer.Stop()
+	}
+}
+
+// ExpireAllAt schedules a Purge of the whole cache at t, replacing any
+// previously scheduled one. Entries added after the purge get the normal TTL.
+func (c *LRU) ExpireAllAt(t time.Time) {
+	c.lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	if c.expireAll != nil {
+		c.expireAll.Stop()
+	}
+	c.expireAll = time.AfterFunc(t.Sub(c.now()), c.Purge)
+}
+
+// Reset empties the cache like Purge but keeps the allocated maps and list,
+// which avoids garbage in loops that repeatedly fill and clear the cache.
+func (c *LRU) Reset() {
+	c.lock()
+	defer c.unlock()
+	c.flush(len(c.items))
+	for k, ent := range c.items {
+		c.release(k, ent.Value.(*Item).value(), reasonPurged)
+	}
+	clear(c.items)
+	for i := range c.buckets {
+		clear(c.buckets[i].entries)
+		c.buckets[i].newestEntry = time.Time{}
+		c.buckets[i].stale = false
+	}
+	c.queue.Init()
+	c.totalSize = 0
+	clear(c.pq)
+	c.pq = c.pq[:0]
+	clear(c.tags)
+	clear(c.expiryQueue)
+	c.expiryQueue = c.expiryQueue[:0]
+	clear(c.failures)
+}
+
+// Compact reallocates the cache's maps and heaps at their current size,
+// releasing the memory they kept from past peaks; Go maps never shrink on
+// their own. The list needs no rebuilding since its elements are allocated
+// and freed one by one. Order, deadlines and all other state are unchanged.
+// Compact copies every entry under the lock, so it is meant to be called
+// rarely, such as after a burst of churn.
+func (c *LRU) Compact() {
+	c.lock()
 	defer c.mu.Unlock()
-	now := time.Now()
+	c.items = compactMap(c.items)
+	for i := range c.buckets {
+		c.buckets[i].entries = compactMap(c.buckets[i].entries)
+	}
+	for t, keys := range c.tags {
+		c.tags[t] = compactMap(keys)
+	}
+	c.tags = compactMap(c.tags)
+	c.pq = slices.Clip(slices.Clone(c.pq))
+	c.expiryQueue = slices.Clip(slices.Clone(c.expiryQueue))
+	c.calls = compactMap(c.calls)
+	c.failures = compactMap(c.failures)
+}
+
+// compactMap copies m into a map allocated for its current size.
+func compactMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	res := make(map[K]V, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+func (c *LRU) Add(key string, value any) {
+	_ = c.TryAdd(key, value)
+}
+
+// ForceAdd adds an entry like Add, bypassing the WithAdmissionSampling and
+// WithTinyLFU admission filters.
+func (c *LRU) ForceAdd(key string, value any) {
+	c.lock()
+	defer c.unlock()
+	if c.closed {
+		return
+	}
+	_ = c.checkedAdd(key, value, c.ttl, c.now(), addForce)
+}
+
+func (c *LRU) TryAdd(key string, value any) error {
+	return c.tryAdd(key, value, c.ttl)
+}
+
+// SetRanked adds keys[i] with values[i] so that keys[0] ends up the most
+// recently used and the last keys are the first to be evicted. Keys ranked
+// beyond the capacity are not added.
+func (c *LRU) SetRanked(keys []string, values []any) {
+	n := min(len(keys), len(values))
+	c.lock()
+	defer c.unlock()
+	if c.closed {
+		return
+	}
+	if c.cap > 0 {
+		n = min(n, c.cap)
+	}
+	now := c.now()
+	for i := n - 1; i >= 0; i-- {
+		_ = c.checkedAdd(keys[i], values[i], c.ttl, now, 0)
+	}
+}
+
+// AddWithTTL adds an entry that expires after ttl instead of the cache's
+// default TTL. A ttl of zero or less means the entry never expires; it is
+// kept out of the expiry buckets but is still subject to capacity eviction.
+func (c *LRU) AddWithTTL(key string, value any, ttl time.Duration) {
+	_ = c.tryAdd(key, value, ttl)
+}
+
+// Update replaces the value and TTL of a live entry like AddWithTTL but
+// leaves its position in the recency order alone, so the write doesn't make
+// a cold entry hot. It reports whether key held a live entry that now holds
+// value; a missing key is not added. A nil value rejected by WithNilDeletes
+// removes the entry and Update returns false.
+func (c *LRU) Update(key string, value any, ttl time.Duration) bool {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	ent, ok := c.items[key]
+	if c.closed || !ok || ent.Value.(*Item).expired(now) {
+		return false
+	}
+	err := c.checkedAdd(key, value, ttl, now, addNoPromote)
+	return err == nil && (value != nil || !c.rejectNil)
+}
+
+// AddAt adds an entry as if it had been added at createdAt with ttl, for
+// rebuilding a cache from historical data: its CreatedAt is createdAt and it
+// expires at createdAt.Add(ttl). An entry that has already expired by then
+// is not added. A ttl of zero adds an entry that never expires. Overwriting
+// a live entry gives it createdAt and the new deadline too. The write itself,
+// eviction included, happens at the clock's current time.
+func (c *LRU) AddAt(key string, value any, createdAt time.Time, ttl time.Duration) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	if c.closed || ttl > 0 && !createdAt.Add(ttl).After(now) {
+		return
+	}
+	if c.checkedAdd(key, value, ttl, now, 0) != nil || value == nil && c.rejectNil {
+		return
+	}
+	ent, ok := c.items[key]
+	if !ok {
+		return
+	}
+	item := ent.Value.(*Item)
+	c.removeFromBucket(ent)
+	item.CreatedAt = createdAt
+	item.ttl = ttl
+	item.ExpiresAt = expiresAt(createdAt, ttl)
+	c.addToBucket(ent)
+}
+
+func (c *LRU) tryAdd(key string, value any, ttl time.Duration) error {
+	c.lock()
+	defer c.unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	return c.checkedAdd(key, value, ttl, c.now(), 0)
+}
+
+// addFlags modify a single write.
+type addFlags uint8
+
+const (
+	// addNoPromote leaves the recency of an overwritten entry alone, as
+	// WithWriteDoesNotPromote does for every write.
+	addNoPromote addFlags = 1 << iota
+	// addForce bypasses the admission filters, for ForceAdd.
+	addForce
+)
+
+func (c *LRU) checkedAdd(key string, value any, ttl time.Duration, now time.Time, flags addFlags) error {
+	if c.maxKeyLen > 0 && len(key) > c.maxKeyLen {
+		c.stats.rejectedKeys.Add(1)
+		return ErrKeyTooLong
+	}
+	if value == nil && c.rejectNil {
+		if ent, ok := c.items[key]; ok && c.nilDeletes {
+			c.removeElement(ent, reasonRemoved)
+		}
+		return nil
+	}
+	if c.writeThrough != nil {
+		return c.addWriteThrough(key, value, ttl, now, flags)
+	}
+	c.add(key, value, ttl, now, flags)
+	return nil
+}
+
+func (c *LRU) add(key string, value any, ttl time.Duration, now time.Time, flags addFlags) {
+	delete(c.failures, key)
+	if c.copyOnWrite != nil {
+		value = c.copyOnWrite(value)
+	}
+	if c.sketch != nil {
+		c.sketch.add(key)
+	}
 
 	if ent, ok := c.items[key]; ok {
-		c.queue.MoveToFront(ent)
-		c.removeFromBucket(ent)
-		ent.Value.(*Item).Value = value
-		ent.Value.(*Item).ExpiresAt = now.Add(c.ttl)
-		c.addToBucket(ent)
+		item := ent.Value.(*Item)
+		keepTTL := c.absoluteLifetime && !item.expired(now)
+		if c.equal != nil && !item.expired(now) && c.equal(item.value(), value) {
+			if !c.keepTTLOnEqual && !keepTTL {
+				c.removeFromBucket(ent)
+				item.ttl = ttl
+				item.ExpiresAt = expiresAt(now, ttl)
+				c.addToBucket(ent)
+			}
+			return
+		}
+		if c.policy != PolicyFIFO && !c.writeNoPromote && flags&addNoPromote == 0 {
+			c.queue.MoveToFront(ent)
+		}
+		if old := item.value(); !sameValue(old, value) {
+			c.release(key, old, reasonReplaced)
+		}
+		ent.Value.(*Item).Value = c.store(value)
+		ent.Value.(*Item).Version++
+		if !keepTTL {
+			c.removeFromBucket(ent)
+			if c.absoluteLifetime {
+				item.CreatedAt = now
+			}
+			item.ttl = ttl
+			item.ExpiresAt = expiresAt(now, ttl)
+			c.addToBucket(ent)
+		}
+		c.updatePriority(ent, false)
+		c.resize(ent)
 		return
 	}
 
-	if len(c.items) == c.cap {
+	if c.cap > 0 && len(c.items) >= c.cap {
+		if flags&addForce == 0 && !c.admit(key) {
+			return
+		}
 		c.removeOldest()
 	}
 
 	ent := &Item{
 		Key:       key,
-		Value:     value,
-		ExpiresAt: now.Add(c.ttl),
+		Value:     c.store(value),
+		CreatedAt: now,
+		ExpiresAt: expiresAt(now, ttl),
+		Version:   1,
+		ttl:       ttl,
 	}
 	element := c.queue.PushFront(ent)
 	c.items[key] = element
 	c.addToBucket(element)
+	c.updatePriority(element, true)
+	c.resize(element)
 }
 
-func (c *LRU) Get(key string) (any, bool) {
-	c.mu.Lock()
+func (c *LRU) TotalSize() int64 {
+	c.lock()
 	defer c.mu.Unlock()
+	return c.totalSize
+}
+
+// Bytes is TotalSize, for caches created with NewLRU2.
+func (c *LRU) Bytes() int64 {
+	return c.TotalSize()
+}
+
+func (c *LRU) resize(e *list.Element) {
+	if c.maxSize <= 0 {
+		return
+	}
+	item := e.Value.(*Item)
+	size := int64(1)
+	if c.sizeFn != nil {
+		size = c.sizeFn(item.value())
+	} else if sizer, ok := item.value().(Sizer); ok {
+		size = sizer.Size()
+	}
+	c.totalSize += size - item.size
+	item.size = size
+	if size > c.maxSize {
+		// No amount of eviction makes room for it.
+		c.removeElement(e, reasonEvicted)
+		return
+	}
+
+	for c.totalSize > c.maxSize {
+		ent := c.evictionVictim(e)
+		if ent == nil {
+			return
+		}
+		c.removeElement(ent, reasonEvicted)
+	}
+}
+
+func (c *LRU) Get(key string) (any, bool) {
+	return c.GetWithTTL(key, 0)
+}
+
+// Lookup is the same as Get. It matches simple.LRU, whose Get returns only
+// the value, so both satisfy cache.Cache.
+func (c *LRU) Lookup(key string) (any, bool) {
+	return c.Get(key)
+}
+
+// GetWithTTL is like Get, but a value that WithLoader loads on a miss is
+// cached for ttl. The TTL of a loaded value is, in order of precedence, a
+// non-zero ttl, the non-zero TTL returned by the loader, or the cache's
+// default TTL. Without a loader GetWithTTL is the same as Get.
+func (c *LRU) GetWithTTL(key string, ttl time.Duration) (any, bool) {
+	if c.loader != nil {
+		value, err := c.load(key, func() (any, time.Duration, error) {
+			value, loaded, err := c.loader(key)
+			switch {
+			case ttl != 0:
+				loaded = ttl
+			case loaded == 0:
+				loaded = c.ttl
+			}
+			return value, loaded, err
+		})
+		return value, err == nil
+	}
+	c.lock()
+	defer c.unlock()
+	return c.get(key, c.now())
+}
+
+// GetQuiet reads key like Get, counting as a hit or miss and an access,
+// but leaves the entry's recency alone, so scans don't disturb the eviction
+// order. It never calls the WithLoader loader.
+func (c *LRU) GetQuiet(key string) (any, bool) {
+	c.lock()
+	defer c.unlock()
+	return c.read(key, c.now(), false)
+}
+
+func (c *LRU) get(key string, now time.Time) (any, bool) {
+	return c.read(key, now, true)
+}
+
+func (c *LRU) read(key string, now time.Time, promote bool) (any, bool) {
+	if c.sketch != nil {
+		c.sketch.add(key)
+	}
 	ent, ok := c.items[key]
 	if ok {
-		if time.Now().After(ent.Value.(*Item).ExpiresAt) {
+		if ent.Value.(*Item).expired(now) {
+			c.stats.misses.Add(1)
+			if c.eagerExpiry || ent.Value.(*Item).collected() {
+				c.removeElement(ent, reasonExpired)
+			}
 			return nil, false
 		}
-		c.queue.MoveToFront(ent)
-		return ent.Value.(*Item).Value, true
+		c.stats.hits.Add(1)
+		ent.Value.(*Item).Accesses++
+		if promote && c.policy == PolicyLRU {
+			c.promote(ent)
+		}
+		if c.copyOnRead != nil {
+			return c.copyOnRead(ent.Value.(*Item).value()), true
+		}
+		return ent.Value.(*Item).value(), true
 	}
+	c.stats.misses.Add(1)
 	return nil, false
 }
 
-func (c *LRU) Remove(key string) bool {
-	c.mu.Lock()
+// GetVersioned is like Get but also returns the entry's version. Versions
+// start at 1 when a key is inserted and increase on every overwrite; a key
+// inserted again after it left the cache starts over at 1.
+func (c *LRU) GetVersioned(key string) (any, uint64, bool) {
+	c.lock()
+	defer c.unlock()
+	value, ok := c.get(key, c.now())
+	if !ok {
+		return nil, 0, false
+	}
+	return value, c.items[key].Value.(*Item).Version, true
+}
+
+// GetWithRank is like Get but also returns the entry's position in the
+// recency order before the read promoted it, 0 being the most recently used.
+// Finding the position walks the list, so it is O(n).
+func (c *LRU) GetWithRank(key string) (any, int, bool) {
+	c.lock()
+	defer c.unlock()
+	ent, ok := c.items[key]
+	if !ok {
+		c.stats.misses.Add(1)
+		return nil, 0, false
+	}
+	rank := 0
+	for e := c.queue.Front(); e != ent; e = e.Next() {
+		rank++
+	}
+	value, ok := c.get(key, c.now())
+	if !ok {
+		return nil, 0, false
+	}
+	return value, rank, true
+}
+
+// GetAndMaybeRenew reads key like Get and, if the entry will expire within
+// window, renews it to expire newTTL from now. Entries further from expiry,
+// or without expiry, are returned unchanged.
+func (c *LRU) GetAndMaybeRenew(key string, window, newTTL time.Duration) (any, bool, bool) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	value, ok := c.get(key, now)
+	if !ok {
+		return nil, false, false
+	}
+	ent := c.items[key]
+	item := ent.Value.(*Item)
+	if item.ExpiresAt.IsZero() || item.ExpiresAt.Sub(now) > window {
+		return value, false, true
+	}
+	c.renew(ent, now, newTTL)
+	return value, true, true
+}
+
+// EntryView is a snapshot of an entry and its metadata. TTL is the time left
+// before expiry and zero for entries that never expire.
+type EntryView struct {
+	Key       string
+	Value     any
+	TTL       time.Duration
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Version   uint64
+	Accesses  uint64
+}
+
+// GetEntry is like Get but returns the value together with the entry's
+// metadata. The access it records is included in Accesses.
+func (c *LRU) GetEntry(key string) (EntryView, bool) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	value, ok := c.get(key, now)
+	if !ok {
+		return EntryView{}, false
+	}
+	item := c.items[key].Value.(*Item)
+	view := EntryView{
+		Key:       key,
+		Value:     value,
+		CreatedAt: item.CreatedAt,
+		ExpiresAt: item.ExpiresAt,
+		Version:   item.Version,
+		Accesses:  item.Accesses,
+	}
+	if !item.ExpiresAt.IsZero() {
+		view.TTL = item.ExpiresAt.Sub(now)
+	}
+	return view, true
+}
+
+func (c *LRU) promote(e *list.Element) {
+	if c.queue.Front() == e {
+		return
+	}
+	c.queue.MoveToFront(e)
+	if c.onPromote != nil {
+		c.protect(func() { c.onPromote(e.Value.(*Item).Key) })
+	}
+}
+
+// LoadOrStore returns the live value for key with loaded set to true, or
+// else adds value with the default TTL and returns it with loaded set to
+// false, as sync.Map's LoadOrStore does. An expired entry counts as
+// missing. The check and the write happen under one lock hold, so of several
+// concurrent callers for a missing key exactly one gets loaded == false. If
+// the cache is closed or rejects the write, loaded is false and nothing is
+// stored.
+func (c *LRU) LoadOrStore(key string, value any) (actual any, loaded bool) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	if v, ok := c.get(key, now); ok {
+		return v, true
+	}
+	if !c.closed {
+		_ = c.checkedAdd(key, value, c.ttl, now, 0)
+	}
+	return value, false
+}
+
+func (c *LRU) GetOrDefault(key string, def any) any {
+	if value, ok := c.Get(key); ok {
+		return value
+	}
+	return def
+}
+
+// MustGet is like Get but panics if key is missing or expired. It is meant
+// only for code paths where a miss is a bug, such as reading a key that was
+// just added and cannot have been evicted; handle misses with Get otherwise.
+func (c *LRU) MustGet(key string) any {
+	value, ok := c.Get(key)
+	if !ok {
+		panic(fmt.Sprintf("ttl: MustGet: key %q is not in the cache", key))
+	}
+	return value
+}
+
+// Len returns the number of resident entries in O(1), including expired
+// entries the sweeper has not removed yet. Use it for cheap monitoring.
+func (c *LRU) Len() int {
+	c.lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// IsExpired reports whether key is resident and whether it has expired,
+// without updating recency or removing anything.
+func (c *LRU) IsExpired(key string) (bool, bool) {
+	c.lock()
+	defer c.mu.Unlock()
+	ent, ok := c.items[key]
+	if !ok {
+		return false, false
+	}
+	return ent.Value.(*Item).expired(c.now()), true
+}
+
+// Cap returns the capacity. Zero means the entry count is unbounded.
+func (c *LRU) Cap() int {
+	c.lock()
+	defer c.mu.Unlock()
+	return c.cap
+}
+
+// Full reports whether the next Add of a new key would evict an entry to
+// respect the capacity. It is always false for unbounded caches.
+func (c *LRU) Full() bool {
+	c.lock()
+	defer c.mu.Unlock()
+	return c.cap > 0 && len(c.items) >= c.cap
+}
+
+// LiveLen returns the number of unexpired entries. It scans the whole cache
+// under the lock, so prefer Len unless the exact count matters.
+func (c *LRU) LiveLen() int {
+	c.lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	n := 0
+	for _, ent := range c.items {
+		if !ent.Value.(*Item).expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// ExpiredCount returns the number of expired entries still resident, those
+// a SweepExpired call would remove. Like LiveLen it scans the whole cache
+// under the lock.
+func (c *LRU) ExpiredCount() int {
+	c.lock()
 	defer c.mu.Unlock()
+	now := c.now()
+	n := 0
+	for _, ent := range c.items {
+		if ent.Value.(*Item).expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// ForEachKey calls fn with the key of each live entry, from the most to the
+// least recently used, until fn returns false. No snapshot is taken: the
+// cache lock is held for the whole iteration, so fn must not call back into
+// the cache and should return quickly.
+func (c *LRU) ForEachKey(fn func(key string) bool) {
+	c.lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for ent := c.queue.Front(); ent != nil; ent = ent.Next() {
+		if item := ent.Value.(*Item); !item.expired(now) && !fn(item.Key) {
+			return
+		}
+	}
+}
+
+// Action tells RangeMutable what to do after visiting an entry.
+type Action int
+
+const (
+	// Keep leaves the entry in the cache and goes on to the next one.
+	Keep Action = iota
+	// Delete removes the entry and goes on to the next one.
+	Delete
+	// Stop leaves the entry in the cache and ends the iteration.
+	Stop
+)
+
+// RangeMutable calls fn for each live entry, from the most to the least
+// recently used, and removes the entries for which fn returns Delete. The
+// removals are applied once the walk ends, under the same lock hold. As with
+// ForEachKey, fn must not call back into the cache.
+func (c *LRU) RangeMutable(fn func(key string, value any) Action) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	var deleted []*list.Element
+	for ent := c.queue.Front(); ent != nil; ent = ent.Next() {
+		item := ent.Value.(*Item)
+		if item.expired(now) {
+			continue
+		}
+		action := fn(item.Key, item.value())
+		if action == Stop {
+			break
+		}
+		if action == Delete {
+			deleted = append(deleted, ent)
+		}
+	}
+	for _, ent := range deleted {
+		c.removeElement(ent, reasonRemoved)
+	}
+}
+
+// MatchGlob returns the live entries whose key matches pattern, using
+// path.Match syntax. It scans every key under the lock and doesn't update
+// recency. A malformed pattern matches nothing.
+func (c *LRU) MatchGlob(pattern string) map[string]any {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil
+	}
+	c.lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	res := make(map[string]any)
+	for key, ent := range c.items {
+		if ent.Value.(*Item).expired(now) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, key); ok {
+			res[key] = ent.Value.(*Item).value()
+		}
+	}
+	return res
+}
+
+// AgeHistogram counts entries by the time since they were inserted. Given
+// ascending boundaries b, the result has len(b)+1 counts: ages below b[0],
+// ages in [b[i-1], b[i]), and ages of at least b[len(b)-1].
+func (c *LRU) AgeHistogram(buckets []time.Duration) []int {
+	c.lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	counts := make([]int, len(buckets)+1)
+	for _, ent := range c.items {
+		age := now.Sub(ent.Value.(*Item).CreatedAt)
+		counts[sort.Search(len(buckets), func(i int) bool { return age < buckets[i] })]++
+	}
+	return counts
+}
+
+// KeyExpiry is an entry's key and deadline, as returned by ExpiryOrder.
+type KeyExpiry struct {
+	Key       string
+	ExpiresAt time.Time
+}
+
+// ExpiryOrder returns the live entries that have a deadline, soonest to
+// expire first. Entries without an expiry are left out. The entries are
+// collected under the lock and sorted after it is released.
+func (c *LRU) ExpiryOrder() []KeyExpiry {
+	c.lock()
+	now := c.now()
+	order := make([]KeyExpiry, 0, len(c.items))
+	for _, ent := range c.items {
+		item := ent.Value.(*Item)
+		if item.ExpiresAt.IsZero() || item.expired(now) {
+			continue
+		}
+		order = append(order, KeyExpiry{Key: item.Key, ExpiresAt: item.ExpiresAt})
+	}
+	c.mu.Unlock()
+	sort.Slice(order, func(i, j int) bool { return order[i].ExpiresAt.Before(order[j].ExpiresAt) })
+	return order
+}
+
+func (c *LRU) Remove(key string) bool {
+	c.lock()
+	defer c.unlock()
+	delete(c.failures, key)
 	if ent, ok := c.items[key]; ok {
-		c.removeElement(ent)
+		c.removeElement(ent, reasonRemoved)
 		return true
 	}
 	return false
 }
 
+// Rename moves the live entry under oldKey to newKey, keeping its recency,
+// deadline, version and tags, and reports whether oldKey held a live entry.
+// An entry already under newKey is replaced by it: its value is closed by
+// WithAutoClose and passed to the WithFinalizer function, as for an
+// overwrite, but OnEvict and OnExpire don't fire. A newKey longer than
+// WithMaxKeyLen allows leaves the cache unchanged and reports false.
+func (c *LRU) Rename(oldKey, newKey string) bool {
+	c.lock()
+	defer c.unlock()
+	ent, ok := c.items[oldKey]
+	if !ok || ent.Value.(*Item).expired(c.now()) {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if c.maxKeyLen > 0 && len(newKey) > c.maxKeyLen {
+		c.stats.rejectedKeys.Add(1)
+		return false
+	}
+	if dst, ok := c.items[newKey]; ok {
+		c.removeElement(dst, reasonReplaced)
+	}
+	item := ent.Value.(*Item)
+	tags := item.tags
+	c.untag(ent)
+	inBucket := !c.precise && !item.ExpiresAt.IsZero()
+	if inBucket {
+		delete(c.buckets[item.ExpireBucket].entries, oldKey)
+	}
+	delete(c.items, oldKey)
+	item.Key = newKey
+	c.items[newKey] = ent
+	if inBucket {
+		c.buckets[item.ExpireBucket].entries[newKey] = ent
+	}
+	c.tag(ent, tags)
+	return true
+}
+
+// RemoveOldest removes the least recently used entry. Recency is the list
+// order, never a timestamp, so entries that were never accessed after being
+// added are removed in exactly their insertion order, however close together
+// they were added.
 func (c *LRU) RemoveOldest() (string, any, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.lock()
+	defer c.unlock()
 	if ent := c.queue.Back(); ent != nil {
-		c.removeElement(ent)
-		return ent.Value.(*Item).Key, ent.Value.(*Item).Value, true
+		c.removeElement(ent, reasonRemoved)
+		return ent.Value.(*Item).Key, ent.Value.(*Item).value(), true
 	}
 	return "", nil, false
 }
 
-func (c *LRU) removeOldest() {
+func (c *LRU) RemoveExpiredOrOldest() (string, any, bool) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	for ent := c.queue.Back(); ent != nil; ent = ent.Prev() {
+		if ent.Value.(*Item).expired(now) {
+			c.removeElement(ent, reasonExpired)
+			return ent.Value.(*Item).Key, ent.Value.(*Item).value(), true
+		}
+	}
 	if ent := c.queue.Back(); ent != nil {
-		c.removeElement(ent)
+		c.removeElement(ent, reasonRemoved)
+		return ent.Value.(*Item).Key, ent.Value.(*Item).value(), true
+	}
+	return "", nil, false
+}
+
+// Drain removes entries from the oldest to the newest, passing each live one
+// to fn after it has been removed, until the cache is empty or fn returns
+// false. Expired entries are removed without being passed to fn. fn runs
+// without the cache lock held.
+func (c *LRU) Drain(fn func(key string, value any) bool) {
+	for {
+		c.lock()
+		ent := c.queue.Back()
+		if ent == nil {
+			c.mu.Unlock()
+			return
+		}
+		item := ent.Value.(*Item)
+		live := !item.expired(c.now())
+		if live {
+			c.removeElement(ent, reasonDrained)
+		} else {
+			c.removeElement(ent, reasonExpired)
+		}
+		c.unlock()
+
+		if live && !fn(item.Key, item.value()) {
+			return
+		}
 	}
 }
 
-func (c *LRU) removeElement(e *list.Element) {
+// TransferTo moves the live entries of c into dst, from the oldest to the
+// newest so dst keeps their recency order, each with its remaining TTL.
+// Expired entries are dropped and c is left empty. The two caches are never
+// locked at the same time.
+func (c *LRU) TransferTo(dst *LRU) {
+	type entry struct {
+		key   string
+		value any
+		ttl   time.Duration
+	}
+
+	c.lock()
+	now := c.now()
+	entries := make([]entry, 0, len(c.items))
+	for ent := c.queue.Back(); ent != nil; ent = c.queue.Back() {
+		item := ent.Value.(*Item)
+		e := entry{key: item.Key, value: item.value()}
+		if !item.ExpiresAt.IsZero() {
+			e.ttl = item.ExpiresAt.Sub(now)
+			if e.ttl <= 0 {
+				c.removeElement(ent, reasonExpired)
+				continue
+			}
+		}
+		entries = append(entries, e)
+		c.removeElement(ent, reasonDrained)
+	}
+	c.unlock()
+
+	for _, e := range entries {
+		dst.AddWithTTL(e.key, e.value, e.ttl)
+	}
+}
+
+// Clone returns an independent cache with the same capacity, default TTL
+// and options as c, holding copies of its live entries. Entries keep their
+// recency order, deadlines, versions, access counts and tags. Values are not
+// deep-copied unless WithCopyOnWrite is set; callbacks, loaders and a shared
+// SweepManager given as options are shared with c. Without WithCopyOnWrite
+// the values stay owned by c, so the clone neither closes them under
+// WithAutoClose nor passes them to the WithFinalizer function.
+func (c *LRU) Clone() *LRU {
+	c.lock()
+	now := c.now()
+	entries := make([]Item, 0, len(c.items))
+	for ent := c.queue.Back(); ent != nil; ent = ent.Prev() {
+		if item := ent.Value.(*Item); !item.expired(now) {
+			entries = append(entries, *item)
+		}
+	}
+	cap, ttl, opts := c.cap, c.ttl, c.opts
+	c.mu.Unlock()
+
+	clone := NewLRU(cap, ttl, opts...)
+	if clone.copyOnWrite == nil {
+		clone.autoClose = false
+		clone.finalizer = nil
+	}
+	clone.lock()
+	defer clone.unlock()
+	for _, item := range entries {
+		var left time.Duration
+		if !item.ExpiresAt.IsZero() {
+			left = item.ExpiresAt.Sub(now)
+		}
+		clone.add(item.Key, item.value(), left, now, 0)
+		ent, ok := clone.items[item.Key]
+		if !ok {
+			continue
+		}
+		copied := ent.Value.(*Item)
+		copied.CreatedAt = item.CreatedAt
+		copied.Version = item.Version
+		copied.Accesses = item.Accesses
+		copied.ttl = item.ttl
+		clone.tag(ent, item.tags)
+	}
+	return clone
+}
+
+// SweepExpired removes every expired entry in a single pass and returns how
+// many were removed. The background sweeper only handles one bucket per tick.
+func (c *LRU) SweepExpired() int {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	removed := 0
+	for _, ent := range c.items {
+		if ent.Value.(*Item).expired(now) {
+			c.removeElement(ent, reasonExpired)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Resize changes the capacity and returns how many entries were evicted to
+// fit it. Evictions run in batches of WithEvictBatchSize entries, each under a
+// single lock hold; the lock is released between batches so a large shrink
+// doesn't block other callers for the whole operation.
+func (c *LRU) Resize(cap int) int {
+	if cap < 0 {
+		cap = 0
+	}
+	c.lock()
+	c.cap = cap
+	c.mu.Unlock()
+
+	evicted := 0
+	for {
+		c.lock()
+		n := 0
+		for c.cap > 0 && len(c.items) > c.cap && (c.evictBatch <= 0 || n < c.evictBatch) {
+			c.removeOldest()
+			n++
+		}
+		done := c.cap == 0 || len(c.items) <= c.cap
+		c.unlock()
+		evicted += n
+		if done {
+			return evicted
+		}
+	}
+}
+
+func (c *LRU) removeOldest() {
+	if ent := c.evictionVictim(nil); ent != nil {
+		c.removeElement(ent, reasonEvicted)
+	}
+}
+
+// evictionVictim returns the entry that capacity and size eviction remove
+// next, other than keep, taking the WithEvictionVeto callback into account.
+// Every eviction and the TinyLFU admission check choose their victim here.
+func (c *LRU) evictionVictim(keep *list.Element) *list.Element {
+	first := c.victimExcept(keep)
+	if first == nil || c.evictionVeto == nil {
+		return first
+	}
+	return c.allowedVictim(first, keep)
+}
+
+// allowedVictim returns the first entry in eviction order, starting at
+// first and skipping keep, that the WithEvictionVeto callback lets go. If
+// every entry is vetoed it returns first.
+func (c *LRU) allowedVictim(first, keep *list.Element) *list.Element {
+	allowed := func(e *list.Element) bool {
+		ok := true
+		c.protect(func() { ok = c.evictionVeto(e.Value.(*Item).Key, e.Value.(*Item).value()) })
+		return ok
+	}
+	if allowed(first) {
+		return first
+	}
+	switch {
+	case c.priority != nil:
+		order := slices.Clone(c.pq)
+		sort.Slice(order, func(i, j int) bool {
+			return order[i].Value.(*Item).priority < order[j].Value.(*Item).priority
+		})
+		for _, e := range order {
+			if e != first && e != keep && allowed(e) {
+				return e
+			}
+		}
+	case c.policy == PolicyMRU:
+		for e := first.Next(); e != nil; e = e.Next() {
+			if e != keep && allowed(e) {
+				return e
+			}
+		}
+	default:
+		for e := first.Prev(); e != nil; e = e.Prev() {
+			if e != keep && allowed(e) {
+				return e
+			}
+		}
+	}
+	return first
+}
+
+func (c *LRU) admit(key string) bool {
+	if c.sampling > 0 && rand.Float64() >= c.sampling {
+		return false
+	}
+	if c.sketch == nil {
+		return true
+	}
+	victim := c.evictionVictim(nil)
+	if victim == nil {
+		return true
+	}
+	return c.sketch.estimate(key) > c.sketch.estimate(victim.Value.(*Item).Key)
+}
+
+// victim returns the entry capacity eviction removes next. Like RemoveOldest
+// it only looks at the list order, so ties can't occur.
+func (c *LRU) victim() *list.Element {
+	if c.priority != nil {
+		if len(c.pq) == 0 {
+			return nil
+		}
+		return c.pq[0]
+	}
+	if c.policy == PolicyMRU {
+		return c.queue.Front()
+	}
+	return c.queue.Back()
+}
+
+func (c *LRU) removeElement(e *list.Element, r reason) {
+	switch r {
+	case reasonEvicted:
+		c.stats.evictions.Add(1)
+		c.evictionLog.record(e.Value.(*Item).Key, r, c.now())
+	case reasonExpired:
+		c.stats.expirations.Add(1)
+		c.evictionLog.record(e.Value.(*Item).Key, r, c.now())
+	}
 	c.queue.Remove(e)
 	delete(c.items, e.Value.(*Item).Key)
 	c.removeFromBucket(e)
+	c.removePriority(e)
+	c.untag(e)
+	c.totalSize -= e.Value.(*Item).size
+	c.release(e.Value.(*Item).Key, e.Value.(*Item).value(), r)
 }
 
 func (c *LRU) deleteExpired() {
-	c.mu.Lock()
-	bucketIdx := c.nextCleanupBucket
-	timeToExpire := time.Until(c.buckets[bucketIdx].newestEntry)
+	c.lock()
+	b := &c.buckets[c.nextCleanupBucket]
+	timeToExpire := b.deadline().Sub(c.now())
+	if len(b.entries) == 0 {
+		timeToExpire = 0
+	}
+	if timeToExpire > c.interval {
+		timeToExpire = c.interval
+	}
 	if timeToExpire > 0 {
 		c.mu.Unlock()
 		time.Sleep(timeToExpire)
-		c.mu.Lock()
+		c.stats.sleepNanos.Add(int64(timeToExpire))
+		c.lock()
 	}
-	for _, ent := range c.buckets[bucketIdx].entries {
-		c.removeElement(ent)
+	c.sweepBucket(c.now())
+	c.unlock()
+}
+
+// sweepBucket removes the expired entries of the next bucket and advances
+// the sweeper to the following one. With WithSweepBatch it goes on to clear
+// the expired entries of up to n-1 following buckets, without advancing past
+// them, for as long as each bucket it visits had expired entries.
+func (c *LRU) sweepBucket(now time.Time) {
+	defer func(start time.Time) {
+		c.stats.sweeps.Add(1)
+		c.stats.sweepNanos.Add(int64(time.Since(start)))
+	}(time.Now())
+	removed := c.sweepBucketAt(c.nextCleanupBucket, now)
+	for i := 1; i < c.sweepBatch && removed > 0; i++ {
+		removed = c.sweepBucketAt(uint8((int(c.nextCleanupBucket)+i)%numBuckets), now)
 	}
 	c.nextCleanupBucket = (c.nextCleanupBucket + 1) % numBuckets
-	c.mu.Unlock()
 }
 
+func (c *LRU) sweepBucketAt(i uint8, now time.Time) int {
+	b := &c.buckets[i]
+	newest := time.Time{}
+	removed := 0
+	for _, ent := range b.entries {
+		item := ent.Value.(*Item)
+		if item.expired(now) {
+			c.removeElement(ent, reasonExpired)
+			removed++
+		} else if item.ExpiresAt.After(newest) {
+			newest = item.ExpiresAt
+		}
+	}
+	b.newestEntry = newest
+	b.stale = false
+	return removed
+}
+
+func (c *LRU) SweeperState() (uint8, []int) {
+	c.lock()
+	defer c.mu.Unlock()
+	sizes := make([]int, len(c.buckets))
+	for i, b := range c.buckets {
+		sizes[i] = len(b.entries)
+	}
+	return c.nextCleanupBucket, sizes
+}
+
+// addToBucket places an entry in the bucket the sweeper reaches around its
+// expiry. Entries outliving a full lap of the ring go to the last bucket and
+// stay there until a sweep finds them expired.
 func (c *LRU) addToBucket(e *list.Element) {
-	bucketId := (numBuckets + c.nextCleanupBucket - 1) % numBuckets
+	if e.Value.(*Item).ExpiresAt.IsZero() {
+		return
+	}
+	if c.precise {
+		c.addToExpiryQueue(e)
+		return
+	}
+	if c.interval == 0 {
+		c.interval = defaultSweepInterval
+		if c.started {
+			c.launchSweeper()
+		}
+	}
+	ticks := numBuckets
+	ttl := e.Value.(*Item).ExpiresAt.Sub(c.now())
+	if n := int((ttl + c.interval - 1) / c.interval); n < ticks {
+		ticks = max(n, 1)
+	}
+	bucketId := uint8((int(c.nextCleanupBucket) + ticks - 1) % numBuckets)
+	if c.maxBucketSize > 0 {
+		bucketId = c.spill(bucketId)
+	}
 	e.Value.(*Item).ExpireBucket = bucketId
 	c.buckets[bucketId].entries[e.Value.(*Item).Key] = e
 	if c.buckets[bucketId].newestEntry.Before(e.Value.(*Item).ExpiresAt) {
@@ -188,6 +1539,32 @@ func (c *LRU) addToBucket(e *list.Element) {
 	}
 }
 
+// spill returns the first bucket from id onwards that has room under
+// WithMaxBucketSize, or id if every bucket is full.
+func (c *LRU) spill(id uint8) uint8 {
+	for i := 0; i < numBuckets; i++ {
+		b := uint8((int(id) + i) % numBuckets)
+		if len(c.buckets[b].entries) < c.maxBucketSize {
+			return b
+		}
+	}
+	return id
+}
+
 func (c *LRU) removeFromBucket(e *list.Element) {
-	delete(c.buckets[e.Value.(*Item).ExpireBucket].entries, e.Value.(*Item).Key)
+	if e.Value.(*Item).ExpiresAt.IsZero() {
+		return
+	}
+	if c.precise {
+		c.removeFromExpiryQueue(e)
+		return
+	}
+	b := &c.buckets[e.Value.(*Item).ExpireBucket]
+	delete(b.entries, e.Value.(*Item).Key)
+	if len(b.entries) == 0 {
+		b.newestEntry = time.Time{}
+		b.stale = false
+	} else if !e.Value.(*Item).ExpiresAt.Before(b.newestEntry) {
+		b.stale = true
+	}
 }
@@ -0,0 +1,1049 @@
+package ttl
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedGetOrComputeLoadsOnce(t *testing.T) {
+	c := NewShardedLRU(4, 100, time.Minute)
+	defer c.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrCompute("k", func() (any, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			if err != nil || v != 42 {
+				t.Errorf("GetOrCompute = %v, %v, want 42, nil", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("compute ran %d times, want 1", n)
+	}
+}
+
+func TestLoadOrStoreStoresOnce(t *testing.T) {
+	c := NewLRU(10, time.Minute)
+	defer c.Close()
+
+	const n = 64
+	var stored atomic.Int32
+	var winner atomic.Value
+	start := make(chan struct{})
+	actuals := make([]any, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			actual, loaded := c.LoadOrStore("k", i)
+			if !loaded {
+				stored.Add(1)
+				winner.Store(i)
+			}
+			actuals[i] = actual
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := stored.Load(); got != 1 {
+		t.Fatalf("%d goroutines stored the value, want 1", got)
+	}
+	for i, actual := range actuals {
+		if actual != winner.Load() {
+			t.Fatalf("goroutine %d got %v, want the stored value %v", i, actual, winner.Load())
+		}
+	}
+}
+
+func TestGetOrComputePanicDoesNotWedgeKey(t *testing.T) {
+	c := NewLRU(10, time.Minute)
+	defer c.Close()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was not propagated")
+			}
+		}()
+		_, _ = c.GetOrCompute("k", func() (any, error) { panic("boom") })
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := c.GetOrCompute("k", func() (any, error) { return 1, nil })
+		if err != nil || v != 1 {
+			t.Errorf("GetOrCompute = %v, %v, want 1, nil", v, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetOrCompute blocked after a panicking compute")
+	}
+}
+
+func TestErrorTTLFailures(t *testing.T) {
+	c := NewLRU(0, time.Minute, WithErrorTTL(time.Minute))
+	defer c.Close()
+	errFailed := errors.New("failed")
+	fail := func() (any, error) { return nil, errFailed }
+
+	for i := 0; i < 2*maxFailures; i++ {
+		_, _ = c.GetOrCompute(strconv.Itoa(i), fail)
+	}
+	if n := len(c.failures); n > maxFailures {
+		t.Fatalf("%d failures remembered, want at most %d", n, maxFailures)
+	}
+
+	for name, forget := range map[string]func(){
+		"Add":    func() { c.Add("0", 1) },
+		"Remove": func() { c.Remove("0") },
+		"Purge":  c.Purge,
+		"Reset":  c.Reset,
+	} {
+		c.Remove("0")
+		if _, err := c.GetOrCompute("0", fail); err != errFailed {
+			t.Fatalf("GetOrCompute error = %v, want %v", err, errFailed)
+		}
+		forget()
+		if _, ok := c.failures["0"]; ok {
+			t.Fatalf("%s did not forget the failure", name)
+		}
+	}
+}
+
+func TestLoadedValueIsCopiedOnRead(t *testing.T) {
+	c := NewLRU(10, time.Minute, WithCopyOnRead(func(v any) any {
+		return append([]int(nil), v.([]int)...)
+	}))
+	defer c.Close()
+
+	v, err := c.GetOrCompute("k", func() (any, error) { return []int{1}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.([]int)[0] = 2
+	if got, _ := c.Get("k"); got.([]int)[0] != 1 {
+		t.Fatalf("cached value changed through the loaded result: %v", got)
+	}
+}
+
+func TestClosedCache(t *testing.T) {
+	c := NewLRU(10, time.Minute)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Close()
+	c.Close()
+
+	c.Add("c", 3)
+	if _, ok := c.Get("c"); ok {
+		t.Fatal("Add after Close inserted an entry")
+	}
+	if err := c.TryAdd("c", 3); !errors.Is(err, ErrClosed) {
+		t.Fatalf("TryAdd after Close = %v, want ErrClosed", err)
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get after Close = %v, %v, want 1, true", v, ok)
+	}
+	if !c.Remove("a") {
+		t.Fatal("Remove after Close reported a miss")
+	}
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len after Close = %d, want 1", n)
+	}
+	c.Purge()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len after Purge = %d, want 0", n)
+	}
+}
+
+func TestNewLRUFromFuncFiresSeedEvictions(t *testing.T) {
+	var evicted []string
+	var full []bool
+	c := NewLRUFromFunc(2, 0, func(add func(k string, v any)) {
+		for _, k := range []string{"a", "b", "c", "d"} {
+			add(k, 1)
+		}
+	}, WithOnEvict(func(key string, _ any) {
+		evicted = append(evicted, key)
+	}), WithOnFull(func(f bool) {
+		full = append(full, f)
+	}))
+	defer c.Close()
+
+	if len(evicted) != 2 || evicted[0] != "a" || evicted[1] != "b" {
+		t.Fatalf("evicted while seeding = %v, want [a b]", evicted)
+	}
+	if len(full) != 1 || !full[0] {
+		t.Fatalf("full transitions while seeding = %v, want [true]", full)
+	}
+	c.Get("zz")
+	if len(evicted) != 2 || len(full) != 1 {
+		t.Fatalf("callbacks fired again on a later Get: %v, %v", evicted, full)
+	}
+}
+
+func TestRemovalCallbacksMayCallBack(t *testing.T) {
+	var c *LRU
+	c = NewLRU(2, 0, WithOnEvict(func(key string, _ any) {
+		if key == "a" {
+			c.Remove("b")
+			c.Add("evicted-"+key, 1)
+		}
+	}), WithOnExpire(func(key string, _ any) {
+		c.Add("expired-"+key, 1)
+	}))
+	defer c.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Add("a", 1)
+		c.Add("b", 1)
+		c.Add("c", 1)
+		c.AddWithTTL("x", 1, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		c.SweepExpired()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("callback calling into the cache deadlocked")
+	}
+	if _, ok := c.Get("expired-x"); !ok {
+		t.Fatal("OnExpire callback's Add was lost")
+	}
+	if err := c.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamSkipsCollectedWeakValues(t *testing.T) {
+	type big struct{ buf [1 << 16]byte }
+	c := NewLRU(10, 0, WithWeakValues())
+	defer c.Close()
+
+	kept := &big{}
+	c.Add("kept", kept)
+	c.Add("dropped", &big{})
+	runtime.GC()
+	runtime.GC()
+
+	var keys []string
+	for item := range c.Stream(context.Background()) {
+		if item.Value == nil {
+			t.Fatalf("streamed %q with a nil value", item.Key)
+		}
+		keys = append(keys, item.Key)
+	}
+	if len(keys) != 1 || keys[0] != "kept" {
+		t.Fatalf("streamed keys = %v, want [kept]", keys)
+	}
+	runtime.KeepAlive(kept)
+}
+
+func TestPurgeDuringConcurrentAccess(t *testing.T) {
+	c := NewLRU(64, 5*time.Millisecond)
+	defer c.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := strconv.Itoa((g*31 + i) % 128)
+				if i%2 == 0 {
+					c.Add(key, i)
+				} else if v, ok := c.Get(key); ok && v == nil {
+					t.Errorf("Get(%q) returned a nil value", key)
+				}
+			}
+		}(g)
+	}
+	for deadline := time.Now().Add(200 * time.Millisecond); time.Now().Before(deadline); {
+		c.Purge()
+		if err := c.HealthCheck(); err != nil {
+			t.Error(err)
+			break
+		}
+		runtime.Gosched()
+	}
+	close(stop)
+	wg.Wait()
+
+	if err := c.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Len(); n > 64 {
+		t.Fatalf("Len = %d after concurrent Purge, want at most 64", n)
+	}
+}
+
+func TestCopyOnWriteDisablesWeakValues(t *testing.T) {
+	type big struct{ buf [1 << 16]byte }
+	c := NewLRU(10, 0, WithWeakValues(), WithCopyOnWrite(func(v any) any {
+		copied := *v.(*big)
+		return &copied
+	}))
+	defer c.Close()
+
+	v := &big{}
+	c.Add("k", v)
+	runtime.GC()
+	runtime.GC()
+	if _, ok := c.Get("k"); !ok {
+		t.Fatal("stored copy was collected")
+	}
+	runtime.KeepAlive(v)
+}
+
+func TestBucketsAfterExpireAndPurge(t *testing.T) {
+	c := NewLRU(100, 10*time.Millisecond)
+	defer c.Close()
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 50; i++ {
+			c.Add(strconv.Itoa(i), i)
+		}
+		if err := c.HealthCheck(); err != nil {
+			t.Fatalf("round %d after fill: %v", round, err)
+		}
+		time.Sleep(15 * time.Millisecond)
+		if _, ok := c.Get("0"); ok {
+			t.Fatalf("round %d: expired entry returned", round)
+		}
+		if err := c.HealthCheck(); err != nil {
+			t.Fatalf("round %d after expiry: %v", round, err)
+		}
+		c.Purge()
+		if err := c.HealthCheck(); err != nil {
+			t.Fatalf("round %d after purge: %v", round, err)
+		}
+		for i, n := range c.Internal().BucketSizes {
+			if n != 0 {
+				t.Fatalf("round %d: bucket %d holds %d entries after Purge", round, i, n)
+			}
+		}
+	}
+}
+
+func TestBucketDeadlineAfterRemovingNewest(t *testing.T) {
+	c := NewLRU(10, 0, WithSweeper(time.Hour))
+	defer c.Close()
+
+	c.AddWithTTL("old", 1, time.Minute)
+	c.AddWithTTL("new", 1, time.Minute+time.Second)
+	oldAt, _ := c.GetEntry("old")
+	bucket := -1
+	for i, n := range c.Internal().BucketSizes {
+		if n == 2 {
+			bucket = i
+		}
+	}
+	if bucket < 0 {
+		t.Fatalf("entries not in one bucket: %v", c.Internal().BucketSizes)
+	}
+
+	for _, remove := range []func(){
+		func() { c.Remove("new") },
+		func() {
+			c.AddWithTTL("new", 1, time.Minute+time.Second)
+			c.RenewMulti([]string{"new"}, time.Millisecond)
+		},
+	} {
+		remove()
+		if err := c.HealthCheck(); err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Internal().NewestEntries[bucket]; !got.Equal(oldAt.ExpiresAt) {
+			t.Fatalf("bucket deadline = %v, want %v", got, oldAt.ExpiresAt)
+		}
+	}
+}
+
+func TestEvictionOrderFollowsInsertionOnTies(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	var evicted []string
+	c := NewLRU(3, time.Minute, WithClock(clock), WithOnEvict(func(key string, _ any) {
+		evicted = append(evicted, key)
+	}))
+	defer c.Close()
+
+	for _, k := range []string{"a", "b", "c", "d", "e", "f"} {
+		c.Add(k, 1)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(evicted, want) {
+		t.Fatalf("evicted = %v, want %v", evicted, want)
+	}
+
+	c.Get("d")
+	var removed []string
+	for {
+		key, _, ok := c.RemoveOldest()
+		if !ok {
+			break
+		}
+		removed = append(removed, key)
+	}
+	if want := []string{"e", "f", "d"}; !slices.Equal(removed, want) {
+		t.Fatalf("RemoveOldest order = %v, want %v", removed, want)
+	}
+}
+
+// BenchmarkTinyLFUZipf replays a Zipf-distributed key stream through a cache
+// holding 1% of the key space and reports the hit ratio with and without
+// TinyLFU admission.
+func BenchmarkTinyLFUZipf(b *testing.B) {
+	const keys, capacity = 100_000, 1_000
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"LRU", nil},
+		{"TinyLFU", []Option{WithTinyLFU()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.01, 1, keys-1)
+			stream := make([]string, 1<<16)
+			for i := range stream {
+				stream[i] = strconv.FormatUint(zipf.Uint64(), 10)
+			}
+			c := NewLRU(capacity, 0, bc.opts...)
+			defer c.Close()
+
+			var hits int
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := stream[i%len(stream)]
+				if _, ok := c.Get(key); ok {
+					hits++
+				} else {
+					c.Add(key, i)
+				}
+			}
+			b.ReportMetric(float64(hits)/float64(b.N), "hit-ratio")
+		})
+	}
+}
+
+// BenchmarkResizeShrink shrinks a full cache to 1% of its capacity and
+// reports the longest Get a concurrent reader waited meanwhile, unbatched
+// and with WithEvictBatchSize.
+func BenchmarkResizeShrink(b *testing.B) {
+	const capacity = 100_000
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Unbatched", nil},
+		{"Batch1000", []Option{WithEvictBatchSize(1000)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var worst time.Duration
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				c := NewLRU(capacity, 0, bc.opts...)
+				for k := 0; k < capacity; k++ {
+					c.Add(strconv.Itoa(k), k)
+				}
+				stop := make(chan struct{})
+				reader := make(chan time.Duration)
+				go func() {
+					var longest time.Duration
+					for {
+						select {
+						case <-stop:
+							reader <- longest
+							return
+						default:
+						}
+						start := time.Now()
+						c.Get("0")
+						longest = max(longest, time.Since(start))
+						runtime.Gosched()
+					}
+				}()
+				b.StartTimer()
+
+				c.Resize(capacity / 100)
+
+				b.StopTimer()
+				close(stop)
+				worst = max(worst, <-reader)
+				c.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(worst.Nanoseconds()), "max-get-ns")
+		})
+	}
+}
+
+func TestPriorityEvictsMinimum(t *testing.T) {
+	type weighted struct{ priority, size int64 }
+	newCache := func(capacity int, maxSize int64, evicted *[]string) *LRU {
+		return NewLRU(capacity, 0,
+			WithPriority(func(v any) int64 { return v.(weighted).priority }),
+			WithMaxSize(maxSize),
+			WithSizeFunc(func(v any) int64 { return v.(weighted).size }),
+			WithOnEvict(func(key string, _ any) { *evicted = append(*evicted, key) }))
+	}
+
+	t.Run("capacity", func(t *testing.T) {
+		var evicted []string
+		c := newCache(3, 100, &evicted)
+		defer c.Close()
+		c.Add("a", weighted{5, 1})
+		c.Add("b", weighted{1, 1})
+		c.Add("c", weighted{3, 1})
+		c.Get("b")
+		c.Add("d", weighted{4, 1})
+		c.Add("e", weighted{6, 1})
+		if want := []string{"b", "c"}; !slices.Equal(evicted, want) {
+			t.Fatalf("evicted = %v, want %v", evicted, want)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		var evicted []string
+		c := newCache(10, 10, &evicted)
+		defer c.Close()
+		c.Add("a", weighted{5, 3})
+		c.Add("b", weighted{1, 3})
+		c.Add("c", weighted{3, 3})
+		// d has the lowest priority but is the entry being written, so size
+		// eviction removes the next lowest instead.
+		c.Add("d", weighted{0, 4})
+		if want := []string{"b"}; !slices.Equal(evicted, want) {
+			t.Fatalf("evicted = %v, want %v", evicted, want)
+		}
+		for _, k := range []string{"a", "c", "d"} {
+			if _, ok := c.Get(k); !ok {
+				t.Fatalf("%q was evicted", k)
+			}
+		}
+		if err := c.HealthCheck(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestEagerExpiryRemovesOnGet(t *testing.T) {
+	for _, eager := range []bool{false, true} {
+		clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+		var expired []string
+		opts := []Option{WithClock(clock), WithSweeper(time.Hour), WithOnExpire(func(key string, _ any) {
+			expired = append(expired, key)
+		})}
+		if eager {
+			opts = append(opts, WithEagerExpiry())
+		}
+		c := NewLRU(10, time.Second, opts...)
+
+		c.Add("k", 1)
+		clock.Advance(2 * time.Second)
+		if _, ok := c.Get("k"); ok {
+			t.Fatalf("eager=%v: Get returned an expired entry", eager)
+		}
+		wantLen, wantExpired := 1, []string(nil)
+		if eager {
+			wantLen, wantExpired = 0, []string{"k"}
+		}
+		if n := c.Len(); n != wantLen {
+			t.Fatalf("eager=%v: Len = %d after an expired Get, want %d", eager, n, wantLen)
+		}
+		if !slices.Equal(expired, wantExpired) {
+			t.Fatalf("eager=%v: OnExpire fired for %v, want %v", eager, expired, wantExpired)
+		}
+		if err := c.HealthCheck(); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+}
+
+func TestSweepBatchReclaimsBurst(t *testing.T) {
+	for _, bc := range []struct {
+		batch, left int
+	}{
+		{0, 190},
+		{32, 0},
+	} {
+		clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+		c := NewLRU(0, 0, WithClock(clock), WithSweeper(time.Hour), WithSweepBatch(bc.batch))
+		for b := 1; b <= 20; b++ {
+			for i := 0; i < 10; i++ {
+				c.AddWithTTL(strconv.Itoa(b*10+i), i, time.Duration(b)*time.Hour)
+			}
+		}
+		clock.Advance(24 * time.Hour)
+
+		// One tick of the background sweeper.
+		c.deleteExpired()
+		if n := c.Len(); n != bc.left {
+			t.Fatalf("WithSweepBatch(%d): %d entries left after one tick, want %d", bc.batch, n, bc.left)
+		}
+		if err := c.HealthCheck(); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+}
+
+func TestEntryLargerThanByteBudget(t *testing.T) {
+	var evicted []string
+	c := NewLRU2(10, 10, func(v any) int64 { return int64(len(v.(string))) }, 0,
+		WithOnEvict(func(key string, _ any) { evicted = append(evicted, key) }))
+	defer c.Close()
+
+	c.Add("a", "aaaa")
+	c.Add("big", strings.Repeat("x", 50))
+	if _, ok := c.Get("big"); ok {
+		t.Fatal("entry larger than the byte budget was kept")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("oversized entry evicted an entry that fits")
+	}
+	c.Add("a", strings.Repeat("y", 11))
+	if want := []string{"big", "a"}; !slices.Equal(evicted, want) {
+		t.Fatalf("evicted = %v, want %v", evicted, want)
+	}
+	if n, b := c.Len(), c.Bytes(); n != 0 || b != 0 {
+		t.Fatalf("Len, Bytes = %d, %d, want 0, 0", n, b)
+	}
+	if err := c.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAbsoluteLifetimeCapsRenewals(t *testing.T) {
+	for name, renew := range map[string]func(c *LRU){
+		"Touch":            func(c *LRU) { c.Touch("k") },
+		"RenewMulti":       func(c *LRU) { c.RenewMulti([]string{"k"}, time.Second) },
+		"GetAndMaybeRenew": func(c *LRU) { c.GetAndMaybeRenew("k", time.Second, time.Second) },
+		"Increment":        func(c *LRU) { c.Increment("k", 0) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+			c := NewLRU(10, 100*time.Millisecond, WithClock(clock), WithSweeper(time.Hour), WithAbsoluteLifetime())
+			defer c.Close()
+
+			c.Add("k", int64(1))
+			clock.Advance(80 * time.Millisecond)
+			renew(c)
+			if view, _ := c.GetEntry("k"); !view.ExpiresAt.Equal(view.CreatedAt.Add(100 * time.Millisecond)) {
+				t.Fatalf("deadline moved to %v, created at %v", view.ExpiresAt, view.CreatedAt)
+			}
+			clock.Advance(30 * time.Millisecond)
+			if _, ok := c.Get("k"); ok {
+				t.Fatal("renewed entry outlived its lifetime")
+			}
+			if err := c.HealthCheck(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c := NewLRU(2, 0, WithNilDeletes())
+	defer c.Close()
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if !c.Update("a", 10, time.Minute) {
+		t.Fatal("Update of a live key returned false")
+	}
+	c.Add("c", 3)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Update promoted the entry")
+	}
+	if c.Update("a", 1, 0) {
+		t.Fatal("Update of a missing key returned true")
+	}
+	if c.Update("b", nil, 0) {
+		t.Fatal("Update deleting the key with nil returned true")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("Update with nil didn't delete the key")
+	}
+}
+
+func TestForceAddBypassesAdmission(t *testing.T) {
+	c := NewLRU(2, 0, WithTinyLFU())
+	defer c.Close()
+
+	for _, k := range []string{"a", "b"} {
+		c.Add(k, 1)
+		for i := 0; i < 5; i++ {
+			c.Get(k)
+		}
+	}
+	c.Add("cold", 1)
+	if _, ok := c.Get("cold"); ok {
+		t.Fatal("TinyLFU admitted a cold key over hot ones")
+	}
+	c.ForceAdd("forced", 1)
+	if _, ok := c.Get("forced"); !ok {
+		t.Fatal("ForceAdd was filtered by TinyLFU")
+	}
+	c.Add("cold2", 1)
+	if _, ok := c.Get("cold2"); ok {
+		t.Fatal("admission stayed bypassed after ForceAdd")
+	}
+}
+
+func TestAddAt(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	c := NewLRU(10, time.Hour, WithClock(clock), WithSweeper(time.Hour))
+	defer c.Close()
+	past := clock.Now().Add(-time.Minute)
+
+	c.AddAt("old", 1, past.Add(-time.Hour), time.Hour)
+	if _, ok := c.Get("old"); ok {
+		t.Fatal("AddAt added an entry already expired")
+	}
+
+	c.Add("k", 1)
+	c.AddAt("k", 2, past, 2*time.Minute)
+	view, ok := c.GetEntry("k")
+	if !ok || view.Value != 2 {
+		t.Fatalf("GetEntry = %v, %v after overwriting with AddAt", view.Value, ok)
+	}
+	if !view.CreatedAt.Equal(past) || !view.ExpiresAt.Equal(past.Add(2*time.Minute)) {
+		t.Fatalf("overwritten entry created %v, expires %v, want %v, %v",
+			view.CreatedAt, view.ExpiresAt, past, past.Add(2*time.Minute))
+	}
+	if err := c.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute + time.Nanosecond)
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("entry outlived the deadline AddAt gave it")
+	}
+}
+
+func TestEvictionVetoOnEveryEvictionPath(t *testing.T) {
+	veto := WithEvictionVeto(func(key string, _ any) bool { return key != "keep" })
+
+	t.Run("size", func(t *testing.T) {
+		c := NewLRU2(10, 10, func(v any) int64 { return int64(len(v.(string))) }, 0, veto)
+		defer c.Close()
+		c.Add("keep", "aaaa")
+		c.Add("b", "bbbb")
+		c.Add("c", "cccc")
+		for key, want := range map[string]bool{"keep": true, "b": false, "c": true} {
+			if _, ok := c.Get(key); ok != want {
+				t.Fatalf("Get(%q) hit = %v, want %v", key, ok, want)
+			}
+		}
+	})
+
+	t.Run("TinyLFU", func(t *testing.T) {
+		c := NewLRU(2, 0, WithTinyLFU(), veto)
+		defer c.Close()
+		c.Add("keep", 1)
+		for i := 0; i < 5; i++ {
+			c.Get("keep")
+		}
+		c.Add("cold", 1)
+		c.Get("new")
+		c.Get("new")
+		// new is hotter than cold, the entry it would really replace.
+		c.Add("new", 1)
+		for key, want := range map[string]bool{"keep": true, "cold": false, "new": true} {
+			if _, ok := c.Get(key); ok != want {
+				t.Fatalf("Get(%q) hit = %v, want %v", key, ok, want)
+			}
+		}
+	})
+}
+
+type closeCounter struct{ closed atomic.Int32 }
+
+func (c *closeCounter) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestCloneDoesNotReleaseSharedValues(t *testing.T) {
+	var finalized atomic.Int32
+	c := NewLRU(2, 0, WithAutoClose(), WithFinalizer(func(string, any) {
+		finalized.Add(1)
+	}))
+	defer c.Close()
+	v := &closeCounter{}
+	c.Add("k", v)
+
+	clone := c.Clone()
+	clone.Remove("k")
+	clone.Add("k", v)
+	clone.Add("a", 1)
+	clone.Add("b", 1)
+	clone.Close()
+	time.Sleep(10 * time.Millisecond)
+	if n := v.closed.Load(); n != 0 {
+		t.Fatalf("clone closed a value the original serves %d times", n)
+	}
+	if n := finalized.Load(); n != 0 {
+		t.Fatalf("clone finalized %d values the original serves", n)
+	}
+	if got, ok := c.Get("k"); !ok || got != v {
+		t.Fatalf("original Get = %v, %v after clone mutations", got, ok)
+	}
+
+	c.Remove("k")
+	time.Sleep(10 * time.Millisecond)
+	if n, f := v.closed.Load(), finalized.Load(); n != 1 || f != 1 {
+		t.Fatalf("original removal: closed %d, finalized %d, want 1, 1", n, f)
+	}
+}
+
+func TestCloneOwnsCopiedValues(t *testing.T) {
+	c := NewLRU(10, 0, WithAutoClose(), WithCopyOnWrite(func(v any) any {
+		return &closeCounter{}
+	}))
+	defer c.Close()
+	c.Add("k", &closeCounter{})
+	orig, _ := c.Get("k")
+
+	clone := c.Clone()
+	defer clone.Close()
+	copied, _ := clone.Get("k")
+	clone.Remove("k")
+	if n := copied.(*closeCounter).closed.Load(); n != 1 {
+		t.Fatalf("clone closed its own copy %d times, want 1", n)
+	}
+	if n := orig.(*closeCounter).closed.Load(); n != 0 {
+		t.Fatalf("clone closed the original's value %d times", n)
+	}
+}
+
+func TestTTLList(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	c := NewLRU(10, 0, WithClock(clock))
+	defer c.Close()
+
+	l := &TTLList{Clock: clock}
+	l.Add("forever", 0)
+	l.Add("short", time.Second)
+	c.Add("k", l)
+	if live, _ := c.GetList("k"); !slices.Equal(live, []any{"forever", "short"}) {
+		t.Fatalf("GetList = %v, want [forever short]", live)
+	}
+	clock.Advance(time.Hour)
+	if live, _ := c.GetList("k"); !slices.Equal(live, []any{"forever"}) {
+		t.Fatalf("GetList after an hour = %v, want [forever]", live)
+	}
+}
+
+func TestRename(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+	var events []string
+	c := NewLRU(3, 0, WithClock(clock), WithSweeper(time.Second), WithAutoClose(),
+		WithOnEvict(func(key string, _ any) { events = append(events, "evict "+key) }),
+		WithOnExpire(func(key string, _ any) { events = append(events, "expire "+key) }))
+	defer c.Close()
+
+	c.AddWithTTL("old", 1, 10*time.Second)
+	replaced := &closeCounter{}
+	c.Add("new", replaced)
+	c.Add("other", 1)
+	before, _ := c.GetEntry("old")
+	c.Get("new")
+	c.Get("other")
+
+	if !c.Rename("old", "new") {
+		t.Fatal("Rename of a live key returned false")
+	}
+	if _, ok := c.IsExpired("old"); ok {
+		t.Fatal("old key still resident")
+	}
+	if n := replaced.closed.Load(); n != 1 {
+		t.Fatalf("replaced value closed %d times, want 1", n)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Rename fired %v", events)
+	}
+	if err := c.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The renamed entry kept its place at the back of the recency order.
+	c.Add("a", 1)
+	c.Add("b", 1)
+	if want := []string{"evict new"}; !slices.Equal(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+
+	events = nil
+	c.Purge()
+	c.AddWithTTL("old", 1, 10*time.Second)
+	before, _ = c.GetEntry("old")
+	c.Rename("old", "new")
+	after, _ := c.GetEntry("new")
+	if !after.ExpiresAt.Equal(before.ExpiresAt) || after.Version != before.Version {
+		t.Fatalf("renamed entry = %+v, want the deadline and version of %+v", after, before)
+	}
+	if err := c.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+	// A lap of the sweeper finds the entry in its bucket under the new key.
+	clock.Advance(11 * time.Second)
+	for i := 0; i < numBuckets; i++ {
+		c.lock()
+		c.sweepBucket(c.now())
+		c.unlock()
+	}
+	if want := []string{"expire new"}; !slices.Equal(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+}
+
+// FuzzTTLCache runs byte-encoded sequences of Add, Get, Remove, Purge, clock
+// advances and sweeper ticks against an unbounded cache and a model of it,
+// checking the cache's invariants after every step. The background sweeper
+// is slowed down to an hour per tick so sweeps only happen when an op asks
+// for them; TTLs and advances are multiples of that interval to spread the
+// entries over the bucket ring.
+func FuzzTTLCache(f *testing.F) {
+	f.Add([]byte{0, 1, 10, 1, 1, 4, 20, 1, 1})
+	f.Add([]byte{0, 1, 5, 0, 2, 5, 4, 6, 3, 0, 1, 0, 4, 200, 1, 2})
+	f.Add([]byte{0, 7, 0, 4, 255, 1, 7, 2, 7, 0, 7, 3})
+	f.Add([]byte{0, 1, 3, 0, 2, 120, 5, 12, 6, 0, 5, 0, 1, 1, 2, 1})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		const interval = time.Hour
+		clock := &fakeClock{now: time.Unix(1_000_000, 0)}
+		c := NewLRU(0, 0, WithClock(clock), WithSweeper(interval))
+		defer c.Close()
+		model := make(map[string]time.Time)
+		live := func(key string) bool {
+			at, ok := model[key]
+			return ok && (at.IsZero() || !clock.Now().After(at))
+		}
+		sweep := func() {
+			c.lock()
+			c.sweepBucket(c.now())
+			c.unlock()
+		}
+
+		for len(ops) >= 2 {
+			op, arg := ops[0]%7, ops[1]
+			ops = ops[2:]
+			key := strconv.Itoa(int(arg % 8))
+			switch op {
+			case 0:
+				var ttl time.Duration
+				if len(ops) > 0 {
+					ttl = time.Duration(ops[0]%128) * interval
+					ops = ops[1:]
+				}
+				c.AddWithTTL(key, arg, ttl)
+				model[key] = time.Time{}
+				if ttl > 0 {
+					model[key] = clock.Now().Add(ttl)
+				}
+			case 1:
+				v, ok := c.Get(key)
+				if ok && !live(key) {
+					t.Fatalf("Get(%q) returned %v for an expired or removed entry", key, v)
+				}
+				if !ok && live(key) {
+					t.Fatalf("Get(%q) missed a live entry", key)
+				}
+			case 2:
+				c.Remove(key)
+				delete(model, key)
+			case 3:
+				c.Purge()
+				clear(model)
+			case 4:
+				clock.Advance(time.Duration(arg) * interval / 4)
+			case 5:
+				// One tick of the background sweeper, after the interval it
+				// waits between ticks. deleteExpired would sleep in real time
+				// for a bucket whose deadline is ahead, so this runs the
+				// sweepBucket it ends with.
+				clock.Advance(interval)
+				sweep()
+			case 6:
+				// A full lap over the ring removes every expired entry.
+				for i := 0; i < numBuckets; i++ {
+					sweep()
+				}
+				n := 0
+				for key := range model {
+					if live(key) {
+						n++
+					} else {
+						delete(model, key)
+					}
+				}
+				if got := c.Len(); got != n {
+					t.Fatalf("Len = %d after a full sweep lap, want %d live entries", got, n)
+				}
+			}
+
+			if err := c.HealthCheck(); err != nil {
+				t.Fatal(err)
+			}
+			for key := range model {
+				if !live(key) {
+					continue
+				}
+				if expired, ok := c.IsExpired(key); !ok || expired {
+					t.Fatalf("live entry %q: IsExpired = %v, %v", key, expired, ok)
+				}
+			}
+		}
+	})
+}
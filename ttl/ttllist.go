@@ -0,0 +1,84 @@
+package ttl
+
+import (
+	"sync"
+	"time"
+)
+
+type TimedValue struct {
+	Value     any
+	ExpiresAt time.Time
+}
+
+// TTLList is a cache value holding elements that expire individually. Store
+// a *TTLList under a key and read it with GetList, which drops the expired
+// elements and removes the key once none is left.
+type TTLList struct {
+	// Clock, if set, is used instead of time.Now by Add and Live. Give it
+	// the clock of a cache created with WithClock; GetList always uses the
+	// cache's clock.
+	Clock Clock
+
+	mu     sync.Mutex
+	values []TimedValue
+}
+
+func (l *TTLList) now() time.Time {
+	if l.Clock != nil {
+		return l.Clock.Now()
+	}
+	return time.Now()
+}
+
+// Add appends value, expiring after ttl. A ttl of zero or less means the
+// element never expires, as for cache entries.
+func (l *TTLList) Add(value any, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.values = append(l.values, TimedValue{Value: value, ExpiresAt: expiresAt(l.now(), ttl)})
+}
+
+// Live removes the expired elements and returns the values of the others.
+func (l *TTLList) Live() []any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.prune(l.now())
+}
+
+func (l *TTLList) prune(now time.Time) []any {
+	kept := l.values[:0]
+	var live []any
+	for _, v := range l.values {
+		if v.ExpiresAt.IsZero() || now.Before(v.ExpiresAt) {
+			kept = append(kept, v)
+			live = append(live, v.Value)
+		}
+	}
+	clear(l.values[len(kept):])
+	l.values = kept
+	return live
+}
+
+// GetList reads the *TTLList stored under key like Get and returns its live
+// elements. If none is left the key is removed and GetList reports a miss,
+// as it does when the value is not a *TTLList.
+func (c *LRU) GetList(key string) ([]any, bool) {
+	c.lock()
+	defer c.unlock()
+	value, ok := c.get(key, c.now())
+	if !ok {
+		return nil, false
+	}
+	l, ok := value.(*TTLList)
+	if !ok {
+		return nil, false
+	}
+	l.mu.Lock()
+	live := l.prune(c.now())
+	l.mu.Unlock()
+	if len(live) == 0 {
+		c.removeElement(c.items[key], reasonExpired)
+		return nil, false
+	}
+	return live, true
+}
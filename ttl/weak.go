@@ -0,0 +1,36 @@
+package ttl
+
+// weakRef is a value held through a weak pointer, see WithWeakValues.
+type weakRef interface {
+	// get returns the value, or false once it has been garbage collected.
+	get() (any, bool)
+}
+
+// value returns the entry's value, unwrapping a weak reference. It is nil
+// for a collected value.
+func (i *Item) value() any {
+	if w, ok := i.Value.(weakRef); ok {
+		v, _ := w.get()
+		return v
+	}
+	return i.Value
+}
+
+// collected reports whether the entry's value was held weakly and has been
+// garbage collected.
+func (i *Item) collected() bool {
+	if w, ok := i.Value.(weakRef); ok {
+		_, live := w.get()
+		return !live
+	}
+	return false
+}
+
+// store returns what the cache keeps for value: a weak reference with
+// WithWeakValues, the value itself otherwise.
+func (c *LRU) store(value any) any {
+	if c.weakValues {
+		return makeWeak(value)
+	}
+	return value
+}
@@ -0,0 +1,8 @@
+//go:build !go1.24
+
+package ttl
+
+// makeWeak holds values strongly: weak pointers need Go 1.24.
+func makeWeak(value any) any {
+	return value
+}
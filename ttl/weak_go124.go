@@ -0,0 +1,33 @@
+//go:build go1.24
+
+package ttl
+
+import (
+	"reflect"
+	"unsafe"
+	"weak"
+)
+
+type weakPointer struct {
+	ptr weak.Pointer[byte]
+	typ reflect.Type
+}
+
+func (w weakPointer) get() (any, bool) {
+	p := w.ptr.Value()
+	if p == nil {
+		return nil, false
+	}
+	return reflect.NewAt(w.typ.Elem(), unsafe.Pointer(p)).Interface(), true
+}
+
+// makeWeak wraps a non-nil pointer in a weak reference. Other values, and
+// pointers to zero-size values, which may all share one address, are
+// returned unchanged.
+func makeWeak(value any) any {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Type().Elem().Size() == 0 {
+		return value
+	}
+	return weakPointer{ptr: weak.Make((*byte)(rv.UnsafePointer())), typ: rv.Type()}
+}
@@ -0,0 +1,68 @@
+package ttl
+
+import (
+	"container/list"
+	"time"
+)
+
+func (c *LRU) addWriteThrough(key string, value any, ttl time.Duration, now time.Time, flags addFlags) error {
+	var prev *Item
+	var next *list.Element
+	if ent, ok := c.items[key]; ok {
+		p := *ent.Value.(*Item)
+		prev = &p
+		next = ent.Next()
+	}
+	pending := len(c.removed)
+
+	c.add(key, value, ttl, now, flags)
+	var err error
+	if c.protect(func() { err = c.writeThrough(key, value) }) {
+		err = ErrPanicked
+	}
+	if err != nil && c.rollback {
+		c.rollbackAdd(key, prev, next, pending)
+	}
+	return err
+}
+
+// rollbackAdd undoes the write of key: a new entry is removed and an existing
+// one gets its previous value, TTL, version and place in the recency order
+// back, in front of next, the entry that followed it. Entries evicted to make
+// room for the write stay evicted.
+func (c *LRU) rollbackAdd(key string, prev *Item, next *list.Element, pending int) {
+	ent, ok := c.items[key]
+	if !ok {
+		return
+	}
+	if prev == nil {
+		c.removeElement(ent, reasonRolledBack)
+		return
+	}
+
+	item := ent.Value.(*Item)
+	c.removeFromBucket(ent)
+	item.Value = prev.Value
+	item.CreatedAt = prev.CreatedAt
+	item.ExpiresAt = prev.ExpiresAt
+	item.ttl = prev.ttl
+	item.Version = prev.Version
+	c.addToBucket(ent)
+	if next != nil && c.items[next.Value.(*Item).Key] == next {
+		c.queue.MoveBefore(ent, next)
+	} else {
+		// Whatever followed it was evicted by the write.
+		c.queue.MoveToBack(ent)
+	}
+	c.updatePriority(ent, false)
+	c.resize(ent)
+
+	kept := c.removed[:pending]
+	for _, r := range c.removed[pending:] {
+		if r.key == key && r.reason == reasonReplaced {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	c.removed = kept
+}
@@ -0,0 +1,61 @@
+// Package ttlprom exports the statistics of a ttl.LRU as Prometheus metrics.
+// It lives in a module of its own so the cache packages don't depend on the
+// Prometheus client.
+package ttlprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"lrucache/ttl"
+)
+
+// Collector is a prometheus.Collector reporting a cache's hits, misses,
+// evictions and expirations as counters and its length and capacity as
+// gauges. The values are read from the cache on every scrape.
+type Collector struct {
+	cache *ttl.LRU
+
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	evictions   *prometheus.Desc
+	expirations *prometheus.Desc
+	len         *prometheus.Desc
+	cap         *prometheus.Desc
+}
+
+// NewCollector returns a Collector for cache. Metric names are prefixed with
+// namespace, e.g. namespace_hits_total, and carry constLabels, which tell
+// several caches registered with the same registry apart.
+func NewCollector(cache *ttl.LRU, namespace string, constLabels prometheus.Labels) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, nil, constLabels)
+	}
+	return &Collector{
+		cache:       cache,
+		hits:        desc("hits_total", "Number of reads that found a live entry."),
+		misses:      desc("misses_total", "Number of reads that found no live entry."),
+		evictions:   desc("evictions_total", "Number of entries evicted to stay within capacity."),
+		expirations: desc("expirations_total", "Number of entries removed after their TTL ran out."),
+		len:         desc("entries", "Number of resident entries, including expired ones not yet swept."),
+		cap:         desc("capacity", "Maximum number of entries, 0 if unbounded."),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.expirations
+	ch <- c.len
+	ch <- c.cap
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(stats.Expirations))
+	ch <- prometheus.MustNewConstMetric(c.len, prometheus.GaugeValue, float64(c.cache.Len()))
+	ch <- prometheus.MustNewConstMetric(c.cap, prometheus.GaugeValue, float64(c.cache.Cap()))
+}